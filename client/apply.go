@@ -0,0 +1,49 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/draganm/event-tap/data"
+)
+
+// Apply makes the taps of the namespace match the desired ones in a single server side transaction.
+func (c *Client) Apply(ctx context.Context, ar data.ApplyRequest) (*data.ApplyResult, error) {
+	d, err := json.Marshal(ar)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal apply request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.namespaceURL.JoinPath("apply").String(), bytes.NewReader(d))
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create POST request: %w", err)
+	}
+
+	req.Header.Set("content-type", "application/json")
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not perform POST request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		rd, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
+	}
+
+	resObj := &data.ApplyResult{}
+
+	err = json.NewDecoder(res.Body).Decode(resObj)
+	if err != nil {
+		return nil, fmt.Errorf("could nod unmarshal response object: %w", err)
+	}
+
+	return resObj, nil
+}
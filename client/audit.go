@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/draganm/event-tap/data"
+)
+
+// AuditQuery filters the audit log, zero values don't filter.
+type AuditQuery struct {
+	Since     time.Time
+	Until     time.Time
+	Namespace string
+	TapID     string
+	// After is the sequence number of the last entry already read.
+	After uint64
+	Limit int
+}
+
+func (c *Client) Audit(ctx context.Context, q AuditQuery) ([]data.AuditEntry, error) {
+
+	auditURL := c.baseURL.JoinPath("audit")
+	qv := auditURL.Query()
+	if !q.Since.IsZero() {
+		qv.Set("since", q.Since.Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		qv.Set("until", q.Until.Format(time.RFC3339))
+	}
+	if q.Namespace != "" {
+		qv.Set("namespace", q.Namespace)
+	}
+	if q.TapID != "" {
+		qv.Set("tap", q.TapID)
+	}
+	if q.After > 0 {
+		qv.Set("after", strconv.FormatUint(q.After, 10))
+	}
+	if q.Limit > 0 {
+		qv.Set("limit", strconv.Itoa(q.Limit))
+	}
+	auditURL.RawQuery = qv.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", auditURL.String(), nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create GET request: %w", err)
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not perform GET request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		rd, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
+	}
+
+	resObj := data.AuditLog{}
+
+	err = json.NewDecoder(res.Body).Decode(&resObj)
+	if err != nil {
+		return nil, fmt.Errorf("could nod unmarshal response object: %w", err)
+	}
+
+	return resObj.Entries, nil
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Backup writes a snapshot of the server state database to w.
+func (c *Client) Backup(ctx context.Context, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL.JoinPath("backup").String(), nil)
+
+	if err != nil {
+		return fmt.Errorf("could not create GET request: %w", err)
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("could not perform GET request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		rd, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
+	}
+
+	_, err = io.Copy(w, res.Body)
+	if err != nil {
+		return fmt.Errorf("could not read snapshot: %w", err)
+	}
+
+	return nil
+}
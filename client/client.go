@@ -2,12 +2,24 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
+	"strconv"
 )
 
+// ErrRevisionMismatch is returned when a change made with WithRevision finds the tap at another revision.
+var ErrRevisionMismatch = errors.New("revision mismatch")
+
 type Client struct {
-	tapsURL *url.URL
+	baseURL *url.URL
+	// namespaceURL is the root of the namespaced routes, the base URL for the default namespace.
+	namespaceURL *url.URL
+	tapsURL      *url.URL
+	token        string
+	ifMatch      string
 }
 
 func New(baseURL string) (*Client, error) {
@@ -16,7 +28,58 @@ func New(baseURL string) (*Client, error) {
 		return nil, fmt.Errorf("could not parse base URL: %w", err)
 	}
 
-	return &Client{tapsURL: u.JoinPath("taps")}, nil
+	return &Client{baseURL: u, namespaceURL: u, tapsURL: u.JoinPath("taps")}, nil
+}
+
+// WithNamespace returns a copy of the client that manages the taps of the namespace.
+func (c *Client) WithNamespace(ns string) *Client {
+	cc := *c
+	cc.namespaceURL = c.baseURL.JoinPath("namespaces", ns)
+	cc.tapsURL = cc.namespaceURL.JoinPath("taps")
+	return &cc
+}
+
+// WithToken returns a copy of the client that authenticates its requests with the token.
+func (c *Client) WithToken(token string) *Client {
+	cc := *c
+	cc.token = token
+	return &cc
+}
+
+// WithRevision returns a copy of the client whose changes of a tap only
+// succeed while the tap is at the revision.
+func (c *Client) WithRevision(rev uint64) *Client {
+	cc := *c
+	cc.ifMatch = strconv.Quote(strconv.FormatUint(rev, 10))
+	return &cc
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.token != "" {
+		req.Header.Set("authorization", "Bearer "+c.token)
+	}
+	if c.ifMatch != "" && req.Method != "GET" {
+		req.Header.Set("if-match", c.ifMatch)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+func unexpectedStatus(res *http.Response) error {
+	rd, _ := io.ReadAll(res.Body)
+	err := fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
+	if res.StatusCode == http.StatusPreconditionFailed {
+		return fmt.Errorf("%w: %s", ErrRevisionMismatch, err)
+	}
+	return err
+}
+
+// revisionOf parses the ETag of a response.
+func revisionOf(res *http.Response) (uint64, error) {
+	tag, err := strconv.Unquote(res.Header.Get("etag"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid etag %q: %w", res.Header.Get("etag"), err)
+	}
+	return strconv.ParseUint(tag, 10, 64)
 }
 
 type contextKeyType string
@@ -16,27 +16,47 @@ type CreateTapOptions data.TapOptions
 type createTapResponse data.TapID
 
 func (c *Client) CreateTap(ctx context.Context, options CreateTapOptions) (string, error) {
-	d, err := json.Marshal(options)
+	return c.createTap(ctx, data.CreateTapRequest{TapOptions: data.TapOptions(options)}, "")
+}
+
+// CreateTapWithID creates a tap with an ID chosen by the client. Creating
+// it again with the same options succeeds without creating another tap.
+func (c *Client) CreateTapWithID(ctx context.Context, id string, options CreateTapOptions) (string, error) {
+	return c.createTap(ctx, data.CreateTapRequest{ID: id, TapOptions: data.TapOptions(options)}, "")
+}
+
+// CreateTapIdempotently creates a tap, retrying it with the same key returns
+// the ID of the tap created by the first attempt.
+func (c *Client) CreateTapIdempotently(ctx context.Context, idempotencyKey string, options CreateTapOptions) (string, error) {
+	return c.createTap(ctx, data.CreateTapRequest{TapOptions: data.TapOptions(options)}, idempotencyKey)
+}
+
+func (c *Client) createTap(ctx context.Context, createReq data.CreateTapRequest, idempotencyKey string) (string, error) {
+	d, err := json.Marshal(createReq)
 	if err != nil {
 		return "", fmt.Errorf("could not marshal options: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", c.tapsURL.String(), bytes.NewReader(d))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.tapsURL.String(), bytes.NewReader(d))
 
 	if err != nil {
 		return "", fmt.Errorf("could not create POST request: %w", err)
 	}
 
 	req.Header.Set("content-type", "application/json")
+	if idempotencyKey != "" {
+		req.Header.Set(data.IdempotencyKeyHeader, idempotencyKey)
+	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return "", fmt.Errorf("could not perform POST request: %w", err)
 	}
 
 	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusCreated {
+	// 200 OK is the answer to a repeated create
+	if res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusOK {
 		rd, _ := io.ReadAll(res.Body)
 		return "", fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
 	}
@@ -3,7 +3,6 @@ package client
 import (
 	"context"
 	"fmt"
-	"io"
 	"net/http"
 )
 
@@ -17,7 +16,7 @@ func (c *Client) Delete(ctx context.Context, id string) error {
 		return fmt.Errorf("could not create DELETE request: %w", err)
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("could not perform DELETE request: %w", err)
 	}
@@ -25,8 +24,7 @@ func (c *Client) Delete(ctx context.Context, id string) error {
 	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusNoContent {
-		rd, _ := io.ReadAll(res.Body)
-		return fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
+		return unexpectedStatus(res)
 	}
 
 	return nil
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/draganm/event-tap/data"
+)
+
+// Export returns the taps with their cursors and state, limited to one namespace unless ns is empty.
+func (c *Client) Export(ctx context.Context, ns string) (*data.Archive, error) {
+	exportURL := c.baseURL.JoinPath("export")
+	if ns != "" {
+		q := exportURL.Query()
+		q.Set("namespace", ns)
+		exportURL.RawQuery = q.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", exportURL.String(), nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create GET request: %w", err)
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not perform GET request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		rd, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
+	}
+
+	resObj := &data.Archive{}
+
+	err = json.NewDecoder(res.Body).Decode(resObj)
+	if err != nil {
+		return nil, fmt.Errorf("could nod unmarshal response object: %w", err)
+	}
+
+	return resObj, nil
+}
+
+// Import installs the archived taps. onConflict is one of data.OnConflictFail,
+// data.OnConflictSkip or data.OnConflictOverwrite.
+func (c *Client) Import(ctx context.Context, archive *data.Archive, onConflict string) (*data.ImportResult, error) {
+	d, err := json.Marshal(archive)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal archive: %w", err)
+	}
+
+	importURL := c.baseURL.JoinPath("import")
+	q := importURL.Query()
+	q.Set("on_conflict", onConflict)
+	importURL.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", importURL.String(), bytes.NewReader(d))
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create POST request: %w", err)
+	}
+
+	req.Header.Set("content-type", "application/json")
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not perform POST request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		rd, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
+	}
+
+	resObj := &data.ImportResult{}
+
+	err = json.NewDecoder(res.Body).Decode(resObj)
+	if err != nil {
+		return nil, fmt.Errorf("could nod unmarshal response object: %w", err)
+	}
+
+	return resObj, nil
+}
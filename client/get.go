@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/draganm/event-tap/data"
+)
+
+// GetTap returns the options of a tap.
+func (c *Client) GetTap(ctx context.Context, id string) (*data.TapOptions, error) {
+	opts, _, err := c.GetTapWithRevision(ctx, id)
+	return opts, err
+}
+
+// GetTapWithRevision returns the options of a tap and their revision, which
+// WithRevision uses to detect concurrent changes.
+func (c *Client) GetTapWithRevision(ctx context.Context, id string) (*data.TapOptions, uint64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.tapsURL.JoinPath(id).String(), nil)
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not create GET request: %w", err)
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not perform GET request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		rd, _ := io.ReadAll(res.Body)
+		return nil, 0, fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
+	}
+
+	rev, err := revisionOf(res)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resObj := &data.TapOptions{}
+
+	err = json.NewDecoder(res.Body).Decode(resObj)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could nod unmarshal response object: %w", err)
+	}
+
+	return resObj, rev, nil
+}
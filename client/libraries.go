@@ -0,0 +1,70 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/draganm/event-tap/data"
+)
+
+func (c *Client) PublishLibrary(ctx context.Context, lib data.Library) error {
+	d, err := json.Marshal(lib)
+	if err != nil {
+		return fmt.Errorf("could not marshal library: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL.JoinPath("libraries").String(), bytes.NewReader(d))
+
+	if err != nil {
+		return fmt.Errorf("could not create POST request: %w", err)
+	}
+
+	req.Header.Set("content-type", "application/json")
+
+	res, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("could not perform POST request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusCreated {
+		rd, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
+	}
+
+	return nil
+}
+
+func (c *Client) ListLibraries(ctx context.Context) ([]data.LibraryListEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL.JoinPath("libraries").String(), nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create GET request: %w", err)
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not perform GET request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		rd, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
+	}
+
+	entries := []data.LibraryListEntry{}
+
+	err = json.NewDecoder(res.Body).Decode(&entries)
+	if err != nil {
+		return nil, fmt.Errorf("could nod unmarshal response object: %w", err)
+	}
+
+	return entries, nil
+}
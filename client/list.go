@@ -10,12 +10,26 @@ import (
 	"github.com/draganm/event-tap/data"
 )
 
+// ListFilter selects the listed taps, empty fields match all taps.
+type ListFilter struct {
+	// Selector is a comma separated list of label requirements, such as team=billing,env!=dev.
+	Selector string
+	// Status is one of data.StatusFilterRunning, data.StatusFilterPaused or data.StatusFilterFailing.
+	Status     string
+	NamePrefix string
+}
+
 func (c *Client) List(ctx context.Context) ([]data.TapListEntry, error) {
+	return c.ListFiltered(ctx, ListFilter{})
+}
+
+// ListFiltered lists the taps matching the filter.
+func (c *Client) ListFiltered(ctx context.Context, filter ListFilter) ([]data.TapListEntry, error) {
 
 	entries := []data.TapListEntry{}
 	cursor := ""
 	for {
-		page, err := c.getListPage(ctx, cursor)
+		page, err := c.getListPage(ctx, cursor, filter)
 		if err != nil {
 			return nil, fmt.Errorf("could not get list page: %w", err)
 		}
@@ -32,14 +46,23 @@ func (c *Client) List(ctx context.Context) ([]data.TapListEntry, error) {
 
 }
 
-func (c *Client) getListPage(ctx context.Context, cursor string) (*data.TapListPage, error) {
+func (c *Client) getListPage(ctx context.Context, cursor string, filter ListFilter) (*data.TapListPage, error) {
 
 	u := *c.tapsURL
 
 	tu := &u
 	q := tu.Query()
 	q.Set("cursor", cursor)
-	tu.RawPath = q.Encode()
+	if filter.Selector != "" {
+		q.Set("selector", filter.Selector)
+	}
+	if filter.Status != "" {
+		q.Set("status", filter.Status)
+	}
+	if filter.NamePrefix != "" {
+		q.Set("name_prefix", filter.NamePrefix)
+	}
+	tu.RawQuery = q.Encode()
 
 	req, err := http.NewRequest("GET", tu.String(), nil)
 
@@ -47,7 +70,7 @@ func (c *Client) getListPage(ctx context.Context, cursor string) (*data.TapListP
 		return nil, fmt.Errorf("could not create GET request: %w", err)
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("could not perform GET request: %w", err)
 	}
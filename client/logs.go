@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/draganm/event-tap/data"
+)
+
+// Logs returns the console output of a tap logged after the entry with the given sequence number.
+func (c *Client) Logs(ctx context.Context, id string, after uint64) ([]data.LogEntry, error) {
+
+	logsURL := c.tapsURL.JoinPath(id, "logs")
+	q := logsURL.Query()
+	q.Set("after", strconv.FormatUint(after, 10))
+	logsURL.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", logsURL.String(), nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create GET request: %w", err)
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not perform GET request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		rd, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
+	}
+
+	resObj := data.TapLogs{}
+
+	err = json.NewDecoder(res.Body).Decode(&resObj)
+	if err != nil {
+		return nil, fmt.Errorf("could nod unmarshal response object: %w", err)
+	}
+
+	return resObj.Entries, nil
+}
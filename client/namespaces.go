@@ -0,0 +1,101 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/draganm/event-tap/data"
+)
+
+// ListNamespaces returns the namespaces the token of the client may access.
+func (c *Client) ListNamespaces(ctx context.Context) ([]data.NamespaceListEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL.JoinPath("namespaces").String(), nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create GET request: %w", err)
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not perform GET request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		rd, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
+	}
+
+	resObj := []data.NamespaceListEntry{}
+
+	err = json.NewDecoder(res.Body).Decode(&resObj)
+	if err != nil {
+		return nil, fmt.Errorf("could nod unmarshal response object: %w", err)
+	}
+
+	return resObj, nil
+}
+
+func (c *Client) GetQuota(ctx context.Context, ns string) (*data.NamespaceQuota, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL.JoinPath("namespaces", ns, "quota").String(), nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create GET request: %w", err)
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not perform GET request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		rd, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
+	}
+
+	resObj := &data.NamespaceQuota{}
+
+	err = json.NewDecoder(res.Body).Decode(resObj)
+	if err != nil {
+		return nil, fmt.Errorf("could nod unmarshal response object: %w", err)
+	}
+
+	return resObj, nil
+}
+
+// SetQuota sets the quota of a namespace, creating the namespace if needed.
+func (c *Client) SetQuota(ctx context.Context, ns string, q data.NamespaceQuota) error {
+	d, err := json.Marshal(q)
+	if err != nil {
+		return fmt.Errorf("could not marshal quota: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", c.baseURL.JoinPath("namespaces", ns, "quota").String(), bytes.NewReader(d))
+
+	if err != nil {
+		return fmt.Errorf("could not create PUT request: %w", err)
+	}
+
+	req.Header.Set("content-type", "application/json")
+
+	res, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("could not perform PUT request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent {
+		rd, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
+	}
+
+	return nil
+}
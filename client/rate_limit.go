@@ -0,0 +1,43 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/draganm/event-tap/data"
+)
+
+func (c *Client) SetRateLimit(ctx context.Context, id string, rl data.RateLimit) error {
+
+	d, err := json.Marshal(rl)
+	if err != nil {
+		return fmt.Errorf("could not marshal rate limit: %w", err)
+	}
+
+	rateLimitURL := c.tapsURL.JoinPath(id, "rate_limit")
+
+	req, err := http.NewRequest("PUT", rateLimitURL.String(), bytes.NewReader(d))
+
+	if err != nil {
+		return fmt.Errorf("could not create PUT request: %w", err)
+	}
+
+	req.Header.Set("content-type", "application/json")
+
+	res, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("could not perform PUT request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent {
+		return unexpectedStatus(res)
+	}
+
+	return nil
+
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/draganm/event-tap/data"
+)
+
+// Revisions returns the stored versions of the options of a tap, oldest first.
+func (c *Client) Revisions(ctx context.Context, id string) ([]data.TapRevision, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.tapsURL.JoinPath(id, "revisions").String(), nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create GET request: %w", err)
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not perform GET request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		rd, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
+	}
+
+	resObj := []data.TapRevision{}
+
+	err = json.NewDecoder(res.Body).Decode(&resObj)
+	if err != nil {
+		return nil, fmt.Errorf("could nod unmarshal response object: %w", err)
+	}
+
+	return resObj, nil
+}
+
+// Rollback restarts a tap with the options of an earlier revision, it keeps
+// its position in the buffer and its state.
+func (c *Client) Rollback(ctx context.Context, id string, revision uint64) error {
+	rollbackURL := c.tapsURL.JoinPath(id, "rollback")
+	q := rollbackURL.Query()
+	q.Set("revision", strconv.FormatUint(revision, 10))
+	rollbackURL.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", rollbackURL.String(), nil)
+
+	if err != nil {
+		return fmt.Errorf("could not create POST request: %w", err)
+	}
+
+	return c.expectNoContent(req)
+}
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/draganm/event-tap/data"
+)
+
+// TestTap runs tap options against sample events without installing a tap.
+func (c *Client) TestTap(ctx context.Context, tr data.TapTestRequest) (*data.TapTestResult, error) {
+
+	d, err := json.Marshal(tr)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal test request: %w", err)
+	}
+
+	testURL := c.tapsURL.JoinPath("test")
+
+	req, err := http.NewRequestWithContext(ctx, "POST", testURL.String(), bytes.NewReader(d))
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create POST request: %w", err)
+	}
+
+	req.Header.Set("content-type", "application/json")
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not perform POST request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		rd, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
+	}
+
+	resObj := &data.TapTestResult{}
+
+	err = json.NewDecoder(res.Body).Decode(resObj)
+	if err != nil {
+		return nil, fmt.Errorf("could nod unmarshal response object: %w", err)
+	}
+
+	return resObj, nil
+}
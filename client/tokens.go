@@ -0,0 +1,101 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/draganm/event-tap/data"
+)
+
+// CreateToken creates an API token bound to the namespaces, or to all
+// namespaces when none are given. The returned secret can't be retrieved later.
+func (c *Client) CreateToken(ctx context.Context, name, scope string, namespaces ...string) (*data.CreatedToken, error) {
+	d, err := json.Marshal(data.CreateTokenRequest{Name: name, Scope: scope, Namespaces: namespaces})
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal token request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL.JoinPath("tokens").String(), bytes.NewReader(d))
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create POST request: %w", err)
+	}
+
+	req.Header.Set("content-type", "application/json")
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not perform POST request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusCreated {
+		rd, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
+	}
+
+	resObj := &data.CreatedToken{}
+
+	err = json.NewDecoder(res.Body).Decode(resObj)
+	if err != nil {
+		return nil, fmt.Errorf("could nod unmarshal response object: %w", err)
+	}
+
+	return resObj, nil
+}
+
+func (c *Client) ListTokens(ctx context.Context) ([]data.TokenListEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL.JoinPath("tokens").String(), nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create GET request: %w", err)
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not perform GET request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		rd, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
+	}
+
+	resObj := []data.TokenListEntry{}
+
+	err = json.NewDecoder(res.Body).Decode(&resObj)
+	if err != nil {
+		return nil, fmt.Errorf("could nod unmarshal response object: %w", err)
+	}
+
+	return resObj, nil
+}
+
+func (c *Client) RevokeToken(ctx context.Context, id string) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", c.baseURL.JoinPath("tokens", id).String(), nil)
+
+	if err != nil {
+		return fmt.Errorf("could not create DELETE request: %w", err)
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("could not perform DELETE request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent {
+		rd, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("unexpected status %s: %s", res.Status, string(rd))
+	}
+
+	return nil
+}
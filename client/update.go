@@ -0,0 +1,83 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/draganm/event-tap/data"
+)
+
+// UpdateTap replaces the options of a tap, it keeps its position in the buffer and its state.
+func (c *Client) UpdateTap(ctx context.Context, id string, options CreateTapOptions) error {
+	d, err := json.Marshal(options)
+	if err != nil {
+		return fmt.Errorf("could not marshal options: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", c.tapsURL.JoinPath(id).String(), bytes.NewReader(d))
+
+	if err != nil {
+		return fmt.Errorf("could not create PUT request: %w", err)
+	}
+
+	req.Header.Set("content-type", "application/json")
+
+	return c.expectNoContent(req)
+}
+
+func (c *Client) PauseTap(ctx context.Context, id string) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.tapsURL.JoinPath(id, "pause").String(), nil)
+
+	if err != nil {
+		return fmt.Errorf("could not create POST request: %w", err)
+	}
+
+	return c.expectNoContent(req)
+}
+
+func (c *Client) ResumeTap(ctx context.Context, id string) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.tapsURL.JoinPath(id, "resume").String(), nil)
+
+	if err != nil {
+		return fmt.Errorf("could not create POST request: %w", err)
+	}
+
+	return c.expectNoContent(req)
+}
+
+// Seek moves the cursor of a tap, it continues with the event following lastID.
+// An empty lastID starts from the first event in the buffer.
+func (c *Client) Seek(ctx context.Context, id string, lastID string) error {
+	d, err := json.Marshal(data.Cursor{LastID: lastID})
+	if err != nil {
+		return fmt.Errorf("could not marshal cursor: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", c.tapsURL.JoinPath(id, "cursor").String(), bytes.NewReader(d))
+
+	if err != nil {
+		return fmt.Errorf("could not create PUT request: %w", err)
+	}
+
+	req.Header.Set("content-type", "application/json")
+
+	return c.expectNoContent(req)
+}
+
+func (c *Client) expectNoContent(req *http.Request) error {
+	res, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("could not perform %s request: %w", req.Method, err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent {
+		return unexpectedStatus(res)
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/draganm/event-tap/client"
+	"github.com/draganm/event-tap/data"
+	"github.com/draganm/event-tap/reconcile"
+	"github.com/urfave/cli/v2"
+)
+
+func Command() *cli.Command {
+	return &cli.Command{
+
+		Name:  "apply",
+		Usage: "make the taps of a namespace match a YAML configuration file, taps are matched by name",
+		Flags: []cli.Flag{
+			&cli.PathFlag{
+				Name:     "file",
+				Aliases:  []string{"f"},
+				Usage:    "file with a list of taps under the `taps` key",
+				Required: true,
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "only print the changes",
+			},
+			&cli.BoolFlag{
+				Name:  "prune",
+				Usage: "delete taps that are not in the file",
+			},
+			&cli.BoolFlag{
+				Name:  "server-side",
+				Usage: "apply all changes in a single transaction on the server",
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			cl := client.FromContext(c.Context)
+
+			d, err := os.ReadFile(c.Path("file"))
+			if err != nil {
+				return fmt.Errorf("could not read tap configuration: %w", err)
+			}
+
+			desired, err := reconcile.ParseFile(d)
+			if err != nil {
+				return err
+			}
+
+			var actions []data.ApplyAction
+			if c.Bool("server-side") {
+				res, err := cl.Apply(c.Context, data.ApplyRequest{
+					Taps:   desired,
+					Prune:  c.Bool("prune"),
+					DryRun: c.Bool("dry-run"),
+				})
+				if err != nil {
+					return fmt.Errorf("could not apply taps: %w", err)
+				}
+				actions = res.Actions
+			} else {
+				actions, err = applyClientSide(c.Context, cl, desired, c.Bool("prune"), c.Bool("dry-run"))
+				if err != nil {
+					return err
+				}
+			}
+
+			for _, a := range actions {
+				fields := []string{}
+				for _, ch := range a.Changes {
+					fields = append(fields, ch.Field)
+				}
+				if len(fields) > 0 {
+					fmt.Printf("%-9s %s (%s)\n", a.Action, a.Name, strings.Join(fields, ", "))
+					continue
+				}
+				fmt.Printf("%-9s %s\n", a.Action, a.Name)
+			}
+
+			if c.Bool("dry-run") {
+				fmt.Println("dry run, nothing changed")
+			}
+
+			return nil
+		},
+	}
+}
+
+// applyClientSide plans the changes from the installed taps and performs them one by one.
+func applyClientSide(ctx context.Context, cl *client.Client, desired []data.TapOptions, prune, dryRun bool) ([]data.ApplyAction, error) {
+	entries, err := cl.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not list taps: %w", err)
+	}
+
+	// changes fail when someone else changed the tap since it was read
+	revisions := map[string]uint64{}
+	installed := []reconcile.Installed{}
+	for _, e := range entries {
+		opts, rev, err := cl.GetTapWithRevision(ctx, e.ID)
+		if err != nil {
+			return nil, fmt.Errorf("could not get tap %s: %w", e.Name, err)
+		}
+		revisions[e.ID] = rev
+		installed = append(installed, reconcile.Installed{ID: e.ID, Options: *opts})
+	}
+
+	actions, err := reconcile.Plan(desired, installed, prune)
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return actions, nil
+	}
+
+	byName := map[string]data.TapOptions{}
+	for _, d := range desired {
+		byName[d.Name] = d
+	}
+
+	for i, a := range actions {
+		switch a.Action {
+		case data.ApplyCreate:
+			actions[i].ID, err = cl.CreateTap(ctx, client.CreateTapOptions(byName[a.Name]))
+		case data.ApplyUpdate:
+			err = cl.WithRevision(revisions[a.ID]).UpdateTap(ctx, a.ID, client.CreateTapOptions(byName[a.Name]))
+		case data.ApplyDelete:
+			err = cl.WithRevision(revisions[a.ID]).Delete(ctx, a.ID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not %s tap %s: %w", a.Action, a.Name, err)
+		}
+	}
+
+	return actions, nil
+}
@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/draganm/event-tap/client"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+)
+
+func Command() *cli.Command {
+	return &cli.Command{
+
+		Name:  "audit",
+		Usage: "print the audit log of tap changes",
+		Flags: []cli.Flag{
+			&cli.TimestampFlag{
+				Name:   "since",
+				Layout: time.RFC3339,
+			},
+			&cli.TimestampFlag{
+				Name:   "until",
+				Layout: time.RFC3339,
+			},
+			&cli.StringFlag{
+				Name:  "tap",
+				Usage: "only changes of the tap with this ID",
+			},
+			&cli.StringFlag{
+				Name:  "in-namespace",
+				Usage: "only changes of taps in this namespace",
+			},
+			&cli.IntFlag{
+				Name:  "limit",
+				Value: 100,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			cl := client.FromContext(c.Context)
+
+			q := client.AuditQuery{
+				Namespace: c.String("in-namespace"),
+				TapID:     c.String("tap"),
+				Limit:     c.Int("limit"),
+			}
+			if ts := c.Timestamp("since"); ts != nil {
+				q.Since = *ts
+			}
+			if ts := c.Timestamp("until"); ts != nil {
+				q.Until = *ts
+			}
+
+			entries, err := cl.Audit(c.Context, q)
+			if err != nil {
+				return fmt.Errorf("could not get audit log: %w", err)
+			}
+
+			tw := tablewriter.NewWriter(os.Stdout)
+			tw.SetHeader([]string{"seq", "time", "action", "namespace", "tap", "actor", "client", "changes"})
+			for _, e := range entries {
+				actor := e.ActorName
+				if e.ActorTokenID != "" {
+					actor = fmt.Sprintf("%s (%s)", e.ActorName, e.ActorTokenID)
+				}
+				changes := []string{}
+				for _, ch := range e.Changes {
+					changes = append(changes, fmt.Sprintf("%s: %s -> %s", ch.Field, formatValue(ch.Old), formatValue(ch.New)))
+				}
+				tw.Append([]string{
+					strconv.FormatUint(e.Seq, 10),
+					e.Time.Format(time.RFC3339),
+					e.Action,
+					e.Namespace,
+					e.TapID,
+					actor,
+					e.ClientAddress,
+					strings.Join(changes, "\n"),
+				})
+			}
+			tw.Render()
+			return nil
+		},
+	}
+}
+
+// formatValue shortens long values like code so the table stays readable.
+func formatValue(v any) string {
+	if v == nil {
+		return "-"
+	}
+	d, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	s := string(d)
+	if len(s) > 40 {
+		s = s[:37] + "..."
+	}
+	return s
+}
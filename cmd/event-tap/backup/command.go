@@ -0,0 +1,63 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/draganm/event-tap/client"
+	"github.com/draganm/event-tap/server"
+	"github.com/urfave/cli/v2"
+)
+
+func Command() *cli.Command {
+	return &cli.Command{
+
+		Name:  "backup",
+		Usage: "download a snapshot of the server state",
+		Flags: []cli.Flag{
+			&cli.PathFlag{
+				Name:     "output",
+				Aliases:  []string{"o"},
+				Usage:    "snapshot file",
+				Required: true,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			cl := client.FromContext(c.Context)
+
+			tmp := c.Path("output") + ".partial"
+			f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+			if err != nil {
+				return fmt.Errorf("could not create snapshot file: %w", err)
+			}
+			defer os.Remove(tmp)
+
+			err = cl.Backup(c.Context, f)
+			if err != nil {
+				f.Close()
+				return fmt.Errorf("could not back up server: %w", err)
+			}
+
+			err = f.Close()
+			if err != nil {
+				return fmt.Errorf("could not close snapshot file: %w", err)
+			}
+
+			// a connection dropped mid-stream must not leave a truncated snapshot behind
+			err = server.ValidateSnapshot(tmp)
+			if err != nil {
+				return fmt.Errorf("downloaded snapshot is invalid: %w", err)
+			}
+
+			err = os.Rename(tmp, c.Path("output"))
+			if err != nil {
+				return fmt.Errorf("could not rename snapshot file: %w", err)
+			}
+
+			fmt.Println("snapshot written to", c.Path("output"))
+
+			return nil
+		},
+	}
+}
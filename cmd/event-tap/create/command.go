@@ -1,9 +1,14 @@
 package create
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/draganm/event-tap/client"
+	"github.com/draganm/event-tap/data"
 	"github.com/urfave/cli/v2"
 )
 
@@ -16,28 +21,169 @@ func Command() *cli.Command {
 				Name:     "name",
 				Required: true,
 			},
+			&cli.StringFlag{
+				Name:  "id",
+				Usage: "ID of the tap, generated by the server when not set",
+			},
+			&cli.StringFlag{
+				Name:  "idempotency-key",
+				Usage: "key that makes a retried create return the tap created before",
+			},
+			&cli.StringSliceFlag{
+				Name:  "label",
+				Usage: "label of the tap as key=value, can be repeated",
+			},
 			&cli.StringFlag{
 				Name:     "webhook-url",
 				Required: true,
 			},
 			&cli.StringFlag{
-				Name:     "code",
-				Required: true,
+				Name:  "code",
+				Usage: "code of the transform, not needed for wasm",
+			},
+			&cli.PathFlag{
+				Name:  "wasm-file",
+				Usage: "WebAssembly module of a wasm transform",
+			},
+			&cli.UintFlag{
+				Name:  "wasm-memory-limit-pages",
+				Usage: "memory limit of the wasm module in 64KiB pages",
+			},
+			&cli.Uint64Flag{
+				Name:  "wasm-fuel",
+				Usage: "maximum number of function calls of the wasm module per batch",
+			},
+			&cli.StringFlag{
+				Name:  "transform",
+				Usage: "engine running the code: javascript, jq, cel or wasm",
+				Value: "javascript",
+			},
+			&cli.StringFlag{
+				Name:  "language",
+				Usage: "language of the code: javascript, typescript or module",
+				Value: "javascript",
 			},
 			&cli.IntFlag{
 				Name:  "batch-limit",
 				Value: 100,
 			},
+			&cli.IntFlag{
+				Name:  "min-batch-size",
+				Usage: "collect at least this many mapped events before calling the webhook",
+			},
+			&cli.IntFlag{
+				Name:  "max-batch-bytes",
+				Usage: "call the webhook once the collected events reach this size",
+			},
+			&cli.DurationFlag{
+				Name:  "max-batch-wait",
+				Usage: "call the webhook at the latest this long after the first event was collected",
+			},
+			&cli.Float64Flag{
+				Name:  "requests-per-second",
+				Usage: "maximum number of webhook requests per second, 0 means unlimited",
+			},
+			&cli.Float64Flag{
+				Name:  "events-per-second",
+				Usage: "maximum number of events delivered per second, 0 means unlimited",
+			},
+			&cli.DurationFlag{
+				Name:  "execution-timeout",
+				Usage: "maximum duration of a single call of the tap code",
+			},
+			&cli.StringSliceFlag{
+				Name:  "http-allowed-host",
+				Usage: "host the tap code may look up data from, *.example.com matches subdomains",
+			},
+			&cli.DurationFlag{
+				Name:  "http-timeout",
+				Value: 10 * time.Second,
+			},
+			&cli.DurationFlag{
+				Name:  "http-cache-ttl",
+				Usage: "how long successful GET lookups are cached",
+			},
 		},
 
 		Action: func(c *cli.Context) error {
 			cl := client.FromContext(c.Context)
-			id, err := cl.CreateTap(c.Context, client.CreateTapOptions{
+
+			maxBatchWait := ""
+			if c.IsSet("max-batch-wait") {
+				maxBatchWait = c.Duration("max-batch-wait").String()
+			}
+
+			var wasm *data.WASMOptions
+			if c.IsSet("wasm-file") {
+				module, err := os.ReadFile(c.Path("wasm-file"))
+				if err != nil {
+					return fmt.Errorf("could not read wasm module: %w", err)
+				}
+				wasm = &data.WASMOptions{
+					Module:           module,
+					MemoryLimitPages: uint32(c.Uint("wasm-memory-limit-pages")),
+					Fuel:             c.Uint64("wasm-fuel"),
+				}
+			}
+
+			executionTimeout := ""
+			if c.IsSet("execution-timeout") {
+				executionTimeout = c.Duration("execution-timeout").String()
+			}
+
+			if c.IsSet("id") && c.IsSet("idempotency-key") {
+				return errors.New("use either --id or --idempotency-key")
+			}
+
+			var labels map[string]string
+			for _, l := range c.StringSlice("label") {
+				k, v, found := strings.Cut(l, "=")
+				if !found {
+					return fmt.Errorf("label %q is not key=value", l)
+				}
+				if labels == nil {
+					labels = map[string]string{}
+				}
+				labels[k] = v
+			}
+
+			opts := client.CreateTapOptions{
 				Name:       c.String("name"),
 				Code:       c.String("code"),
+				Transform:  c.String("transform"),
+				WASM:       wasm,
+				Language:   c.String("language"),
 				WebhookURL: c.String("webhook-url"),
 				BatchLimit: c.Int("batch-limit"),
-			})
+				Labels:     labels,
+
+				MinBatchSize:  c.Int("min-batch-size"),
+				MaxBatchBytes: c.Int("max-batch-bytes"),
+				MaxBatchWait:  maxBatchWait,
+
+				RateLimit: &data.RateLimit{
+					RequestsPerSecond: c.Float64("requests-per-second"),
+					EventsPerSecond:   c.Float64("events-per-second"),
+				},
+
+				ExecutionTimeout: executionTimeout,
+				HTTP: &data.HTTPOptions{
+					AllowedHosts: c.StringSlice("http-allowed-host"),
+					Timeout:      c.Duration("http-timeout").String(),
+					CacheTTL:     c.Duration("http-cache-ttl").String(),
+				},
+			}
+
+			var id string
+			var err error
+			switch {
+			case c.IsSet("id"):
+				id, err = cl.CreateTapWithID(c.Context, c.String("id"), opts)
+			case c.IsSet("idempotency-key"):
+				id, err = cl.CreateTapIdempotently(c.Context, c.String("idempotency-key"), opts)
+			default:
+				id, err = cl.CreateTap(c.Context, opts)
+			}
 			if err != nil {
 				return fmt.Errorf("could not list taps: %w", err)
 			}
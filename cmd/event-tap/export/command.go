@@ -0,0 +1,111 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/draganm/event-tap/client"
+	"github.com/draganm/event-tap/data"
+	"github.com/urfave/cli/v2"
+)
+
+func Command() *cli.Command {
+	return &cli.Command{
+
+		Name:  "export",
+		Usage: "write all taps with their cursors and state to an archive",
+		Flags: []cli.Flag{
+			&cli.PathFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "archive file, stdout when not set",
+			},
+			&cli.StringFlag{
+				Name:  "only-namespace",
+				Usage: "only export the taps of this namespace",
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			cl := client.FromContext(c.Context)
+
+			archive, err := cl.Export(c.Context, c.String("only-namespace"))
+			if err != nil {
+				return fmt.Errorf("could not export taps: %w", err)
+			}
+
+			out := os.Stdout
+			if c.IsSet("output") {
+				out, err = os.Create(c.Path("output"))
+				if err != nil {
+					return fmt.Errorf("could not create archive file: %w", err)
+				}
+				defer out.Close()
+			}
+
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(archive)
+			if err != nil {
+				return fmt.Errorf("could not write archive: %w", err)
+			}
+
+			fmt.Fprintln(os.Stderr, "exported", len(archive.Taps), "taps and", len(archive.Libraries), "libraries")
+
+			return nil
+		},
+	}
+}
+
+func ImportCommand() *cli.Command {
+	return &cli.Command{
+
+		Name:  "import",
+		Usage: "install the taps of an archive",
+		Flags: []cli.Flag{
+			&cli.PathFlag{
+				Name:     "file",
+				Aliases:  []string{"f"},
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "on-conflict",
+				Usage: "what to do with taps that already exist: fail, skip or overwrite",
+				Value: data.OnConflictFail,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			cl := client.FromContext(c.Context)
+
+			d, err := os.ReadFile(c.Path("file"))
+			if err != nil {
+				return fmt.Errorf("could not read archive: %w", err)
+			}
+
+			archive := &data.Archive{}
+			err = json.Unmarshal(d, archive)
+			if err != nil {
+				return fmt.Errorf("could not parse archive: %w", err)
+			}
+
+			res, err := cl.Import(c.Context, archive, c.String("on-conflict"))
+			if err != nil {
+				return fmt.Errorf("could not import taps: %w", err)
+			}
+
+			for _, t := range res.Created {
+				fmt.Println("created    ", t)
+			}
+			for _, t := range res.Overwritten {
+				fmt.Println("overwritten", t)
+			}
+			for _, t := range res.Skipped {
+				fmt.Println("skipped    ", t)
+			}
+
+			return nil
+		},
+	}
+}
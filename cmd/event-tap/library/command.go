@@ -0,0 +1,75 @@
+package library
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/draganm/event-tap/client"
+	"github.com/draganm/event-tap/data"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+)
+
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:  "library",
+		Usage: "manage JavaScript libraries shared between taps",
+		Subcommands: []*cli.Command{
+			{
+				Name: "publish",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "name",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "version",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "file",
+						Required: true,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					code, err := os.ReadFile(c.String("file"))
+					if err != nil {
+						return fmt.Errorf("could not read library code: %w", err)
+					}
+
+					cl := client.FromContext(c.Context)
+					err = cl.PublishLibrary(c.Context, data.Library{
+						Name:    c.String("name"),
+						Version: c.String("version"),
+						Code:    string(code),
+					})
+					if err != nil {
+						return fmt.Errorf("could not publish library: %w", err)
+					}
+
+					fmt.Println("published", c.String("name")+"@"+c.String("version"))
+					return nil
+				},
+			},
+			{
+				Name: "ls",
+				Action: func(c *cli.Context) error {
+					cl := client.FromContext(c.Context)
+					entries, err := cl.ListLibraries(c.Context)
+					if err != nil {
+						return fmt.Errorf("could not list libraries: %w", err)
+					}
+
+					tw := tablewriter.NewWriter(os.Stdout)
+					tw.SetHeader([]string{"name", "latest", "versions"})
+					for _, e := range entries {
+						tw.Append([]string{e.Name, e.Latest, strings.Join(e.Versions, ", ")})
+					}
+					tw.Render()
+					return nil
+				},
+			},
+		},
+	}
+}
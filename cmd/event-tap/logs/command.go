@@ -0,0 +1,58 @@
+package logs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/draganm/event-tap/client"
+	"github.com/urfave/cli/v2"
+)
+
+func Command() *cli.Command {
+	return &cli.Command{
+
+		Name:  "logs",
+		Usage: "print the console output of a tap",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "id",
+				Required: true,
+			},
+			&cli.BoolFlag{
+				Name:    "follow",
+				Aliases: []string{"f"},
+				Usage:   "keep printing new output",
+			},
+			&cli.DurationFlag{
+				Name:  "poll-interval",
+				Value: time.Second,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			cl := client.FromContext(c.Context)
+			after := uint64(0)
+			for {
+				entries, err := cl.Logs(c.Context, c.String("id"), after)
+				if err != nil {
+					return fmt.Errorf("could not get logs: %w", err)
+				}
+
+				for _, e := range entries {
+					fmt.Printf("%s %-5s %s\n", e.Time.Format(time.RFC3339), e.Level, e.Message)
+					after = e.Seq
+				}
+
+				if !c.Bool("follow") {
+					return nil
+				}
+
+				select {
+				case <-c.Context.Done():
+					return nil
+				case <-time.After(c.Duration("poll-interval")):
+				}
+			}
+		},
+	}
+}
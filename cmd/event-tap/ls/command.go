@@ -3,6 +3,9 @@ package ls
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/olekukonko/tablewriter"
 
@@ -13,20 +16,48 @@ import (
 func Command() *cli.Command {
 	return &cli.Command{
 		Name: "ls",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "selector",
+				Aliases: []string{"l"},
+				Usage:   "only list taps with matching labels, such as team=billing,env!=dev",
+			},
+			&cli.StringFlag{
+				Name:  "status",
+				Usage: "only list running, paused or failing taps",
+			},
+			&cli.StringFlag{
+				Name:  "name-prefix",
+				Usage: "only list taps with names starting with the prefix",
+			},
+		},
 		Action: func(c *cli.Context) error {
 			cl := client.FromContext(c.Context)
-			entries, err := cl.List(c.Context)
+			entries, err := cl.ListFiltered(c.Context, client.ListFilter{
+				Selector:   c.String("selector"),
+				Status:     c.String("status"),
+				NamePrefix: c.String("name-prefix"),
+			})
 			if err != nil {
 				return fmt.Errorf("could not list taps: %w", err)
 			}
 
 			tw := tablewriter.NewWriter(os.Stdout)
-			tw.SetHeader([]string{"name", "ID", "web hook URL"})
+			tw.SetHeader([]string{"name", "ID", "web hook URL", "status", "revision", "labels"})
 			for _, e := range entries {
-				tw.Append([]string{e.Name, e.ID, e.WebhookURL})
+				tw.Append([]string{e.Name, e.ID, e.WebhookURL, e.Status, strconv.FormatUint(e.Revision, 10), formatLabels(e.Labels)})
 			}
 			tw.Render()
 			return nil
 		},
 	}
 }
+
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -1,37 +1,99 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/draganm/event-tap/client"
+	"github.com/draganm/event-tap/cmd/event-tap/apply"
+	"github.com/draganm/event-tap/cmd/event-tap/audit"
+	"github.com/draganm/event-tap/cmd/event-tap/backup"
 	"github.com/draganm/event-tap/cmd/event-tap/create"
 	"github.com/draganm/event-tap/cmd/event-tap/delete"
+	"github.com/draganm/event-tap/cmd/event-tap/export"
+	"github.com/draganm/event-tap/cmd/event-tap/library"
+	"github.com/draganm/event-tap/cmd/event-tap/logs"
 	"github.com/draganm/event-tap/cmd/event-tap/ls"
+	"github.com/draganm/event-tap/cmd/event-tap/namespace"
+	"github.com/draganm/event-tap/cmd/event-tap/pause"
+	"github.com/draganm/event-tap/cmd/event-tap/ratelimit"
+	"github.com/draganm/event-tap/cmd/event-tap/revisions"
+	"github.com/draganm/event-tap/cmd/event-tap/runlocal"
+	"github.com/draganm/event-tap/cmd/event-tap/seek"
+	"github.com/draganm/event-tap/cmd/event-tap/test"
+	"github.com/draganm/event-tap/cmd/event-tap/token"
+	"github.com/draganm/event-tap/data"
 	"github.com/urfave/cli/v2"
 )
 
+// offlineCommands don't talk to an event-tap server.
+var offlineCommands = map[string]bool{
+	"":          true,
+	"help":      true,
+	"h":         true,
+	"run-local": true,
+}
+
 func main() {
 	app := &cli.App{
 		Name:        "event-tap",
 		Description: "command line utility to control event-tap service",
 		Flags: []cli.Flag{
 			&cli.StringFlag{
-				Name:     "event-tap-server-url",
-				EnvVars:  []string{"EVENT_TAP_SERVER_URL"},
-				Required: true,
+				Name:    "event-tap-server-url",
+				EnvVars: []string{"EVENT_TAP_SERVER_URL"},
+			},
+			&cli.StringFlag{
+				Name:    "namespace",
+				Aliases: []string{"n"},
+				Usage:   "namespace of the taps",
+				Value:   data.DefaultNamespace,
+				EnvVars: []string{"EVENT_TAP_NAMESPACE"},
+			},
+			&cli.StringFlag{
+				Name:    "token",
+				Usage:   "API token used to authenticate requests",
+				EnvVars: []string{"EVENT_TAP_TOKEN"},
 			},
 		},
 		Commands: []*cli.Command{
 			ls.Command(),
 			create.Command(),
 			delete.Command(),
+			ratelimit.Command(),
+			logs.Command(),
+			library.Command(),
+			test.Command(),
+			runlocal.Command(),
+			token.Command(),
+			namespace.Command(),
+			pause.Command(),
+			pause.ResumeCommand(),
+			seek.Command(),
+			audit.Command(),
+			apply.Command(),
+			export.Command(),
+			export.ImportCommand(),
+			backup.Command(),
+			revisions.Command(),
+			revisions.RollbackCommand(),
 		},
 		EnableBashCompletion: true,
 		Before: func(c *cli.Context) error {
+			if offlineCommands[c.Args().First()] {
+				return nil
+			}
+			if !c.IsSet("event-tap-server-url") {
+				return errors.New(`Required flag "event-tap-server-url" not set`)
+			}
 			cl, err := client.New(c.String("event-tap-server-url"))
 			if err != nil {
 				return fmt.Errorf("could not create client: %w", err)
 			}
+			cl = cl.WithNamespace(c.String("namespace"))
+			if c.IsSet("token") {
+				cl = cl.WithToken(c.String("token"))
+			}
 			c.Context = client.ContextWithClient(c.Context, cl)
 			return nil
 		},
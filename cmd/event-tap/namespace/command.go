@@ -0,0 +1,75 @@
+package namespace
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/draganm/event-tap/client"
+	"github.com/draganm/event-tap/data"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+)
+
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:  "namespace",
+		Usage: "list namespaces and manage their quotas",
+		Subcommands: []*cli.Command{
+			{
+				Name: "ls",
+				Action: func(c *cli.Context) error {
+					cl := client.FromContext(c.Context)
+					entries, err := cl.ListNamespaces(c.Context)
+					if err != nil {
+						return fmt.Errorf("could not list namespaces: %w", err)
+					}
+
+					tw := tablewriter.NewWriter(os.Stdout)
+					tw.SetHeader([]string{"name", "taps", "max taps", "max requests per second"})
+					for _, e := range entries {
+						tw.Append([]string{
+							e.Name,
+							strconv.Itoa(e.Taps),
+							strconv.Itoa(e.Quota.MaxTaps),
+							strconv.FormatFloat(e.Quota.MaxRequestsPerSecond, 'f', -1, 64),
+						})
+					}
+					tw.Render()
+					return nil
+				},
+			},
+			{
+				Name:  "set-quota",
+				Usage: "set the quota of a namespace, needs a token with the admin scope",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "name",
+						Required: true,
+					},
+					&cli.IntFlag{
+						Name:  "max-taps",
+						Usage: "maximum number of taps, 0 means unlimited",
+					},
+					&cli.Float64Flag{
+						Name:  "max-requests-per-second",
+						Usage: "maximum webhook requests per second of all taps together, 0 means unlimited",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					cl := client.FromContext(c.Context)
+					err := cl.SetQuota(c.Context, c.String("name"), data.NamespaceQuota{
+						MaxTaps:              c.Int("max-taps"),
+						MaxRequestsPerSecond: c.Float64("max-requests-per-second"),
+					})
+					if err != nil {
+						return fmt.Errorf("could not set quota: %w", err)
+					}
+
+					fmt.Println("quota of", c.String("name"), "set")
+					return nil
+				},
+			},
+		},
+	}
+}
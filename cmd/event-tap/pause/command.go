@@ -0,0 +1,60 @@
+package pause
+
+import (
+	"fmt"
+
+	"github.com/draganm/event-tap/client"
+	"github.com/urfave/cli/v2"
+)
+
+func Command() *cli.Command {
+	return &cli.Command{
+
+		Name:  "pause",
+		Usage: "stop a tap from processing events until it is resumed",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "id",
+				Required: true,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			cl := client.FromContext(c.Context)
+			err := cl.PauseTap(c.Context, c.String("id"))
+			if err != nil {
+				return fmt.Errorf("could not pause tap: %w", err)
+			}
+
+			fmt.Println("paused", c.String("id"))
+
+			return nil
+		},
+	}
+}
+
+func ResumeCommand() *cli.Command {
+	return &cli.Command{
+
+		Name:  "resume",
+		Usage: "continue processing events with a paused tap",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "id",
+				Required: true,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			cl := client.FromContext(c.Context)
+			err := cl.ResumeTap(c.Context, c.String("id"))
+			if err != nil {
+				return fmt.Errorf("could not resume tap: %w", err)
+			}
+
+			fmt.Println("resumed", c.String("id"))
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"fmt"
+
+	"github.com/draganm/event-tap/client"
+	"github.com/draganm/event-tap/data"
+	"github.com/urfave/cli/v2"
+)
+
+func Command() *cli.Command {
+	return &cli.Command{
+
+		Name:  "rate-limit",
+		Usage: "change the rate limit of a tap without restarting it, 0 means unlimited",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "id",
+				Required: true,
+			},
+			&cli.Float64Flag{
+				Name: "requests-per-second",
+			},
+			&cli.Float64Flag{
+				Name: "events-per-second",
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			cl := client.FromContext(c.Context)
+			err := cl.SetRateLimit(c.Context, c.String("id"), data.RateLimit{
+				RequestsPerSecond: c.Float64("requests-per-second"),
+				EventsPerSecond:   c.Float64("events-per-second"),
+			})
+			if err != nil {
+				return fmt.Errorf("could not set rate limit: %w", err)
+			}
+			fmt.Println("rate limit updated")
+			return nil
+		},
+	}
+}
@@ -0,0 +1,169 @@
+package revisions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/draganm/event-tap/client"
+	"github.com/draganm/event-tap/data"
+	"github.com/draganm/event-tap/reconcile"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+)
+
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:  "revisions",
+		Usage: "show how the options of a tap changed over time",
+		Subcommands: []*cli.Command{
+			{
+				Name: "ls",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "id",
+						Required: true,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					cl := client.FromContext(c.Context)
+					revisions, err := cl.Revisions(c.Context, c.String("id"))
+					if err != nil {
+						return fmt.Errorf("could not get revisions: %w", err)
+					}
+
+					tw := tablewriter.NewWriter(os.Stdout)
+					tw.SetHeader([]string{"revision", "created", "name", "transform", "webhook URL"})
+					for _, tr := range revisions {
+						created := ""
+						if !tr.CreatedAt.IsZero() {
+							created = tr.CreatedAt.Format(time.RFC3339)
+						}
+						tw.Append([]string{
+							strconv.FormatUint(tr.Revision, 10),
+							created,
+							tr.Options.Name,
+							tr.Options.Transform,
+							tr.Options.WebhookURL,
+						})
+					}
+					tw.Render()
+					return nil
+				},
+			},
+			{
+				Name:  "diff",
+				Usage: "compare two revisions, by default the latest one with the one before",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "id",
+						Required: true,
+					},
+					&cli.Uint64Flag{
+						Name: "from",
+					},
+					&cli.Uint64Flag{
+						Name: "to",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					cl := client.FromContext(c.Context)
+					revisions, err := cl.Revisions(c.Context, c.String("id"))
+					if err != nil {
+						return fmt.Errorf("could not get revisions: %w", err)
+					}
+
+					byRevision := map[uint64]data.TapOptions{}
+					for _, tr := range revisions {
+						byRevision[tr.Revision] = tr.Options
+					}
+
+					to := revisions[len(revisions)-1].Revision
+					if c.IsSet("to") {
+						to = c.Uint64("to")
+					}
+					if !c.IsSet("from") && to < 2 {
+						return fmt.Errorf("revision %d has no earlier revision", to)
+					}
+					from := to - 1
+					if c.IsSet("from") {
+						from = c.Uint64("from")
+					}
+
+					fromOpts, found := byRevision[from]
+					if !found {
+						return fmt.Errorf("revision %d not found", from)
+					}
+					toOpts, found := byRevision[to]
+					if !found {
+						return fmt.Errorf("revision %d not found", to)
+					}
+
+					return printDiff(from, to, fromOpts, toOpts)
+				},
+			},
+		},
+	}
+}
+
+// printDiff shows the changed code line by line and all other changed options by field.
+func printDiff(from, to uint64, fromOpts, toOpts data.TapOptions) error {
+	fmt.Printf("--- revision %d\n+++ revision %d\n", from, to)
+
+	fromCode, toCode := fromOpts.Code, toOpts.Code
+	fromOpts.Code, toOpts.Code = "", ""
+
+	changes, err := reconcile.Diff(fromOpts, toOpts)
+	if err != nil {
+		return err
+	}
+
+	for _, ch := range changes {
+		o, _ := json.Marshal(ch.Old)
+		n, _ := json.Marshal(ch.New)
+		fmt.Printf("%s: %s -> %s\n", ch.Field, o, n)
+	}
+
+	if fromCode == toCode {
+		return nil
+	}
+
+	fmt.Println("code:")
+	for _, l := range reconcile.DiffLines(fromCode, toCode) {
+		fmt.Println(l)
+	}
+
+	return nil
+}
+
+func RollbackCommand() *cli.Command {
+	return &cli.Command{
+
+		Name:  "rollback",
+		Usage: "restart a tap with the options of an earlier revision, keeping its cursor and state",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "id",
+				Required: true,
+			},
+			&cli.Uint64Flag{
+				Name:     "revision",
+				Required: true,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			cl := client.FromContext(c.Context)
+			err := cl.Rollback(c.Context, c.String("id"), c.Uint64("revision"))
+			if err != nil {
+				return fmt.Errorf("could not roll back tap: %w", err)
+			}
+
+			fmt.Println("rolled back", c.String("id"), "to revision", c.Uint64("revision"))
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,124 @@
+package runlocal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/draganm/event-tap/data"
+	"github.com/draganm/event-tap/taptest"
+	"github.com/urfave/cli/v2"
+)
+
+func Command() *cli.Command {
+	return &cli.Command{
+
+		Name:  "run-local",
+		Usage: "run tap code against events from a file without an event-tap server",
+		Flags: []cli.Flag{
+			&cli.PathFlag{
+				Name:  "code",
+				Usage: "file with the code of the transform, not needed for wasm",
+			},
+			&cli.PathFlag{
+				Name:  "wasm-file",
+				Usage: "WebAssembly module of a wasm transform",
+			},
+			&cli.StringFlag{
+				Name:  "transform",
+				Usage: "engine running the code: javascript, jq, cel or wasm",
+				Value: "javascript",
+			},
+			&cli.StringFlag{
+				Name:  "language",
+				Usage: "language of the code: javascript, typescript or module",
+				Value: "javascript",
+			},
+			&cli.DurationFlag{
+				Name:  "execution-timeout",
+				Usage: "maximum duration of a single call of the tap code",
+			},
+			&cli.PathFlag{
+				Name:     "events",
+				Usage:    "file with one JSON event per line",
+				Required: true,
+			},
+			&cli.PathFlag{
+				Name:  "golden",
+				Usage: "file with the expected output as JSON array",
+			},
+			&cli.BoolFlag{
+				Name:  "update",
+				Usage: "write the output to the golden file instead of comparing it",
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+
+			opts := data.TapOptions{
+				Name:      "local",
+				Transform: c.String("transform"),
+				Language:  c.String("language"),
+			}
+
+			if c.IsSet("code") {
+				code, err := os.ReadFile(c.Path("code"))
+				if err != nil {
+					return fmt.Errorf("could not read code: %w", err)
+				}
+				opts.Code = string(code)
+			}
+
+			if c.IsSet("execution-timeout") {
+				opts.ExecutionTimeout = c.Duration("execution-timeout").String()
+			}
+
+			if c.IsSet("wasm-file") {
+				module, err := os.ReadFile(c.Path("wasm-file"))
+				if err != nil {
+					return fmt.Errorf("could not read wasm module: %w", err)
+				}
+				opts.WASM = &data.WASMOptions{Module: module}
+			}
+
+			events, err := taptest.ReadEventsFile(c.Path("events"))
+			if err != nil {
+				return err
+			}
+
+			res, err := taptest.Run(c.Context, opts, events)
+			if err != nil {
+				return fmt.Errorf("could not run tap code: %w", err)
+			}
+
+			for _, e := range res.Logs {
+				fmt.Fprintf(os.Stderr, "%s %-5s %s\n", e.Time.Format(time.RFC3339), e.Level, e.Message)
+			}
+
+			if res.Error != "" {
+				return errors.New(res.Error)
+			}
+
+			switch {
+			case c.IsSet("golden") && c.Bool("update"):
+				return taptest.WriteGolden(c.Path("golden"), res.Output)
+			case c.IsSet("golden"):
+				return taptest.CompareGolden(c.Path("golden"), res.Output)
+			case c.Bool("update"):
+				return errors.New("--update needs --golden")
+			}
+
+			for _, o := range res.Output {
+				d, err := json.Marshal(o)
+				if err != nil {
+					return fmt.Errorf("could not marshal output: %w", err)
+				}
+				fmt.Println(string(d))
+			}
+
+			return nil
+		},
+	}
+}
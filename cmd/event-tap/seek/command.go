@@ -0,0 +1,38 @@
+package seek
+
+import (
+	"fmt"
+
+	"github.com/draganm/event-tap/client"
+	"github.com/urfave/cli/v2"
+)
+
+func Command() *cli.Command {
+	return &cli.Command{
+
+		Name:  "seek",
+		Usage: "move a tap to another position in the buffer",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "id",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "after",
+				Usage: "ID of the event preceding the next processed one, empty for the first event",
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			cl := client.FromContext(c.Context)
+			err := cl.Seek(c.Context, c.String("id"), c.String("after"))
+			if err != nil {
+				return fmt.Errorf("could not seek tap: %w", err)
+			}
+
+			fmt.Println("moved", c.String("id"))
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,125 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/draganm/event-tap/client"
+	"github.com/draganm/event-tap/data"
+	"github.com/draganm/event-tap/taptest"
+	"github.com/urfave/cli/v2"
+)
+
+func Command() *cli.Command {
+	return &cli.Command{
+
+		Name:  "test",
+		Usage: "run tap code against sample events without creating a tap",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "code",
+				Usage: "code of the transform, not needed for wasm",
+			},
+			&cli.PathFlag{
+				Name:  "wasm-file",
+				Usage: "WebAssembly module of a wasm transform",
+			},
+			&cli.StringFlag{
+				Name:  "transform",
+				Usage: "engine running the code: javascript, jq, cel or wasm",
+				Value: "javascript",
+			},
+			&cli.StringFlag{
+				Name:  "language",
+				Usage: "language of the code: javascript, typescript or module",
+				Value: "javascript",
+			},
+			&cli.DurationFlag{
+				Name:  "execution-timeout",
+				Usage: "maximum duration of a single call of the tap code",
+			},
+			&cli.PathFlag{
+				Name:  "events",
+				Usage: "file with one JSON event per line",
+			},
+			&cli.StringFlag{
+				Name:  "from-buffer-after",
+				Usage: "use the events of the buffer following this ID, empty for the first ones",
+			},
+			&cli.IntFlag{
+				Name:  "limit",
+				Usage: "number of events to read from the buffer",
+				Value: 10,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			cl := client.FromContext(c.Context)
+
+			req := data.TapTestRequest{
+				Options: data.TapOptions{
+					Name:      "test",
+					Code:      c.String("code"),
+					Transform: c.String("transform"),
+					Language:  c.String("language"),
+				},
+			}
+
+			if c.IsSet("execution-timeout") {
+				req.Options.ExecutionTimeout = c.Duration("execution-timeout").String()
+			}
+
+			if c.IsSet("wasm-file") {
+				module, err := os.ReadFile(c.Path("wasm-file"))
+				if err != nil {
+					return fmt.Errorf("could not read wasm module: %w", err)
+				}
+				req.Options.WASM = &data.WASMOptions{Module: module}
+			}
+
+			switch {
+			case c.IsSet("events") && c.IsSet("from-buffer-after"):
+				return errors.New("--events and --from-buffer-after are mutually exclusive")
+			case c.IsSet("events"):
+				events, err := taptest.ReadEventsFile(c.Path("events"))
+				if err != nil {
+					return err
+				}
+				req.Events = events
+			default:
+				req.FromBuffer = &data.BufferRange{
+					After: c.String("from-buffer-after"),
+					Limit: c.Int("limit"),
+				}
+			}
+
+			res, err := cl.TestTap(c.Context, req)
+			if err != nil {
+				return fmt.Errorf("could not test tap: %w", err)
+			}
+
+			for _, o := range res.Output {
+				d, err := json.Marshal(o)
+				if err != nil {
+					return fmt.Errorf("could not marshal output: %w", err)
+				}
+				fmt.Println(string(d))
+			}
+
+			for _, e := range res.Logs {
+				fmt.Fprintf(os.Stderr, "%s %-5s %s\n", e.Time.Format(time.RFC3339), e.Level, e.Message)
+			}
+
+			fmt.Fprintf(os.Stderr, "init: %s, map: %s\n", res.InitDuration, res.MapDuration)
+
+			if res.Error != "" {
+				return errors.New(res.Error)
+			}
+
+			return nil
+		},
+	}
+}
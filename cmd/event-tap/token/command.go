@@ -0,0 +1,91 @@
+package token
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/draganm/event-tap/client"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+)
+
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:  "token",
+		Usage: "manage API tokens, needs a token with the admin scope",
+		Subcommands: []*cli.Command{
+			{
+				Name: "create",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "name",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "scope",
+						Usage: "read, write or admin",
+						Value: "read",
+					},
+					&cli.StringSliceFlag{
+						Name:  "namespace",
+						Usage: "namespace the token is bound to, can be repeated, none binds it to all",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					cl := client.FromContext(c.Context)
+					ct, err := cl.CreateToken(c.Context, c.String("name"), c.String("scope"), c.StringSlice("namespace")...)
+					if err != nil {
+						return fmt.Errorf("could not create token: %w", err)
+					}
+
+					fmt.Fprintln(os.Stderr, "created token", ct.ID, "- it can't be shown again")
+					fmt.Println(ct.Token)
+					return nil
+				},
+			},
+			{
+				Name: "ls",
+				Action: func(c *cli.Context) error {
+					cl := client.FromContext(c.Context)
+					entries, err := cl.ListTokens(c.Context)
+					if err != nil {
+						return fmt.Errorf("could not list tokens: %w", err)
+					}
+
+					tw := tablewriter.NewWriter(os.Stdout)
+					tw.SetHeader([]string{"id", "name", "scope", "namespaces", "created"})
+					for _, e := range entries {
+						namespaces := strings.Join(e.Namespaces, ", ")
+						if namespaces == "" {
+							namespaces = "*"
+						}
+						tw.Append([]string{e.ID, e.Name, e.Scope, namespaces, e.CreatedAt.Format(time.RFC3339)})
+					}
+					tw.Render()
+					return nil
+				},
+			},
+			{
+				Name: "revoke",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "id",
+						Required: true,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					cl := client.FromContext(c.Context)
+					err := cl.RevokeToken(c.Context, c.String("id"))
+					if err != nil {
+						return fmt.Errorf("could not revoke token: %w", err)
+					}
+
+					fmt.Println("revoked", c.String("id"))
+					return nil
+				},
+			},
+		},
+	}
+}
@@ -1,23 +1,309 @@
 package data
 
+import "time"
+
 type TapOptions struct {
 	Name       string `json:"name"`
 	Code       string `json:"code"`
 	WebhookURL string `json:"webhook_url"`
 	BatchLimit int    `json:"batch_limit"`
+
+	// Labels are arbitrary key/value pairs used to select taps when listing them.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Transform selects the engine running the code: javascript (default), jq, cel or wasm.
+	Transform string       `json:"transform,omitempty"`
+	WASM      *WASMOptions `json:"wasm,omitempty"`
+
+	// Language of javascript code: javascript (default), typescript or module.
+	Language string `json:"language,omitempty"`
+
+	// MinBatchSize, MaxBatchBytes and MaxBatchWait make the tap collect mapped
+	// output across polls until one of them is reached.
+	MinBatchSize  int    `json:"min_batch_size,omitempty"`
+	MaxBatchBytes int    `json:"max_batch_bytes,omitempty"`
+	MaxBatchWait  string `json:"max_batch_wait,omitempty"`
+
+	RateLimit *RateLimit `json:"rate_limit,omitempty"`
+
+	// ExecutionTimeout limits a single call of the tap code, including its http lookups.
+	ExecutionTimeout string       `json:"execution_timeout,omitempty"`
+	HTTP             *HTTPOptions `json:"http,omitempty"`
+}
+
+// WASMOptions carry the module of a wasm transform and its limits.
+type WASMOptions struct {
+	Module []byte `json:"module"`
+	// MemoryLimitPages limits the memory of the module in 64KiB pages.
+	MemoryLimitPages uint32 `json:"memory_limit_pages,omitempty"`
+	// Fuel limits the number of function calls per batch.
+	Fuel uint64 `json:"fuel,omitempty"`
+}
+
+// HTTPOptions configure the http lookups available to the tap code.
+type HTTPOptions struct {
+	AllowedHosts []string `json:"allowed_hosts,omitempty"`
+	Timeout      string   `json:"timeout,omitempty"`
+	CacheTTL     string   `json:"cache_ttl,omitempty"`
+}
+
+// RateLimit caps how fast a tap delivers to its receiver. Zero means unlimited.
+type RateLimit struct {
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty"`
+	EventsPerSecond   float64 `json:"events_per_second,omitempty"`
 }
 
 type TapID struct {
 	ID string `json:"id"`
 }
 
+// CreateTapRequest are the options of a new tap. The server generates the ID
+// unless the client chooses one.
+type CreateTapRequest struct {
+	ID string `json:"id,omitempty"`
+	TapOptions
+}
+
+// IdempotencyKeyHeader makes a retried create return the tap created by the first request.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
 type TapListEntry struct {
 	ID         string `json:"id"`
 	Name       string `json:"name"`
 	WebhookURL string `json:"webhook_url"`
+	Status     string `json:"status"`
+	Revision   uint64 `json:"revision"`
+
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
+// Status filters of the tap list, failing taps are neither running nor paused.
+const (
+	StatusFilterRunning = "running"
+	StatusFilterPaused  = "paused"
+	StatusFilterFailing = "failing"
+)
+
 type TapListPage struct {
 	Entries []TapListEntry `json:"entries"`
 	Cursor  string         `json:"cursor,omitempty"`
 }
+
+type LogEntry struct {
+	Seq     uint64    `json:"seq"`
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+}
+
+type TapLogs struct {
+	Entries []LogEntry `json:"entries"`
+}
+
+type Library struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Code    string `json:"code"`
+}
+
+type LibraryListEntry struct {
+	Name     string   `json:"name"`
+	Latest   string   `json:"latest"`
+	Versions []string `json:"versions"`
+}
+
+// TapTestRequest runs tap options against sample events without installing a tap.
+// The events are either given inline or read from the buffer.
+type TapTestRequest struct {
+	Options    TapOptions    `json:"options"`
+	Events     []SampleEvent `json:"events,omitempty"`
+	FromBuffer *BufferRange  `json:"from_buffer,omitempty"`
+}
+
+// SampleEvent is an event given inline, events without ID are numbered.
+type SampleEvent struct {
+	ID    string `json:"id,omitempty"`
+	Event any    `json:"event"`
+}
+
+// BufferRange selects up to Limit events following the event with the ID After.
+type BufferRange struct {
+	After string `json:"after"`
+	Limit int    `json:"limit"`
+}
+
+type TapTestResult struct {
+	Output       []any      `json:"output"`
+	Logs         []LogEntry `json:"logs"`
+	InitDuration string     `json:"init_duration,omitempty"`
+	MapDuration  string     `json:"map_duration,omitempty"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// Scopes of API tokens. Each scope includes the ones before it: write tokens
+// can read and admin tokens can do everything.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+type CreateTokenRequest struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+	// Namespaces the token is bound to, all namespaces when empty.
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// CreatedToken contains the secret of a new token, it is not stored and can't be retrieved later.
+type CreatedToken struct {
+	ID    string `json:"id"`
+	Token string `json:"token"`
+}
+
+type TokenListEntry struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Scope      string    `json:"scope"`
+	Namespaces []string  `json:"namespaces,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// DefaultNamespace holds the taps managed through the routes without a namespace.
+const DefaultNamespace = "default"
+
+// NamespaceQuota limits the taps of a namespace, zero means unlimited.
+type NamespaceQuota struct {
+	MaxTaps int `json:"max_taps,omitempty"`
+	// MaxRequestsPerSecond is the webhook request rate of all taps of the namespace together.
+	MaxRequestsPerSecond float64 `json:"max_requests_per_second,omitempty"`
+}
+
+type NamespaceListEntry struct {
+	Name  string         `json:"name"`
+	Taps  int            `json:"taps"`
+	Quota NamespaceQuota `json:"quota"`
+}
+
+// Cursor is the position of a tap in the buffer, an empty LastID starts from the first event.
+type Cursor struct {
+	LastID string `json:"last_id"`
+}
+
+// Audit actions.
+const (
+	AuditCreate   = "create"
+	AuditUpdate   = "update"
+	AuditDelete   = "delete"
+	AuditPause    = "pause"
+	AuditResume   = "resume"
+	AuditSeek     = "seek"
+	AuditImport   = "import"
+	AuditRollback = "rollback"
+)
+
+// AuditEntry records a change made to a tap.
+type AuditEntry struct {
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Namespace string    `json:"namespace"`
+	TapID     string    `json:"tap_id"`
+	// ActorTokenID is the ID of the token used, empty for the configured
+	// admin token or when authentication is disabled.
+	ActorTokenID  string         `json:"actor_token_id,omitempty"`
+	ActorName     string         `json:"actor_name,omitempty"`
+	ClientAddress string         `json:"client_address"`
+	ForwardedFor  string         `json:"forwarded_for,omitempty"`
+	Changes       []OptionChange `json:"changes,omitempty"`
+}
+
+// OptionChange is a changed field of the tap options, Field is a dot separated path.
+type OptionChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old,omitempty"`
+	New   any    `json:"new,omitempty"`
+}
+
+type AuditLog struct {
+	Entries []AuditEntry `json:"entries"`
+}
+
+// TapRevision is a stored version of the options of a tap. CreatedAt is zero
+// for revisions made before the history was kept.
+type TapRevision struct {
+	Revision  uint64     `json:"revision"`
+	CreatedAt time.Time  `json:"created_at"`
+	Options   TapOptions `json:"options"`
+}
+
+// Apply actions.
+const (
+	ApplyCreate    = "create"
+	ApplyUpdate    = "update"
+	ApplyDelete    = "delete"
+	ApplyUnchanged = "unchanged"
+)
+
+// ApplyRequest makes the taps of a namespace match the desired ones, matched by name.
+type ApplyRequest struct {
+	Taps []TapOptions `json:"taps"`
+	// Prune deletes installed taps that are not desired.
+	Prune  bool `json:"prune,omitempty"`
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+type ApplyAction struct {
+	Action  string         `json:"action"`
+	Name    string         `json:"name"`
+	ID      string         `json:"id,omitempty"`
+	Changes []OptionChange `json:"changes,omitempty"`
+}
+
+type ApplyResult struct {
+	Actions []ApplyAction `json:"actions"`
+}
+
+// ArchiveVersion is the version of the archive format written by exports.
+const ArchiveVersion = 1
+
+// Archive holds taps with their cursors and state, to be imported into another server.
+type Archive struct {
+	Version    int           `json:"version"`
+	ExportedAt time.Time     `json:"exported_at"`
+	Taps       []ArchivedTap `json:"taps"`
+	// Libraries are the library versions the archived taps require.
+	Libraries []ArchivedLibrary `json:"libraries,omitempty"`
+}
+
+type ArchivedTap struct {
+	Namespace string     `json:"namespace"`
+	ID        string     `json:"id"`
+	Options   TapOptions `json:"options"`
+	LastID    string     `json:"last_id,omitempty"`
+	Paused    bool       `json:"paused,omitempty"`
+	// State and Windows hold the raw values of the state the tap code keeps.
+	State   map[string]string `json:"state,omitempty"`
+	Windows map[string]string `json:"windows,omitempty"`
+}
+
+type ArchivedLibrary struct {
+	Name   string `json:"name"`
+	Latest string `json:"latest"`
+	// Versions maps each version to its code.
+	Versions map[string]string `json:"versions"`
+}
+
+// How imports handle taps that already exist.
+const (
+	OnConflictFail      = "fail"
+	OnConflictSkip      = "skip"
+	OnConflictOverwrite = "overwrite"
+)
+
+// ImportResult lists the imported taps as `namespace/id`.
+type ImportResult struct {
+	Created     []string `json:"created"`
+	Overwritten []string `json:"overwritten"`
+	Skipped     []string `json:"skipped"`
+}
@@ -63,6 +63,75 @@ func main() {
 				Value:   "http://localhost:5566",
 				EnvVars: []string{"EVENT_BUFFER_BASE_URL"},
 			},
+			&cli.StringFlag{
+				Name:    "admin-token",
+				Usage:   "token with the admin scope, setting it requires all API requests to be authenticated and allows creating tokens",
+				EnvVars: []string{"ADMIN_TOKEN"},
+			},
+			&cli.IntFlag{
+				Name:    "circuit-breaker-failures",
+				Usage:   "consecutive failed webhook requests that open the circuit breaker of a host",
+				Value:   5,
+				EnvVars: []string{"CIRCUIT_BREAKER_FAILURES"},
+			},
+			&cli.DurationFlag{
+				Name:    "circuit-breaker-cool-down",
+				Usage:   "how long an open circuit breaker rejects webhook requests before letting a probe through",
+				Value:   30 * time.Second,
+				EnvVars: []string{"CIRCUIT_BREAKER_COOL_DOWN"},
+			},
+			&cli.StringSliceFlag{
+				Name:    "lookup-allowed-host",
+				Usage:   "host http lookups of taps may reach, `*.` matches any subdomain; taps can only narrow the list down and can't make lookups without it",
+				EnvVars: []string{"LOOKUP_ALLOWED_HOSTS"},
+			},
+			&cli.DurationFlag{
+				Name:    "dry-run-timeout",
+				Usage:   "how long testing tap code may take at most",
+				Value:   10 * time.Second,
+				EnvVars: []string{"DRY_RUN_TIMEOUT"},
+			},
+			&cli.PathFlag{
+				Name:    "backup-dir",
+				Usage:   "directory for scheduled snapshots of the state, no scheduled backups when not set",
+				EnvVars: []string{"BACKUP_DIR"},
+			},
+			&cli.DurationFlag{
+				Name:    "backup-interval",
+				Usage:   "how often scheduled snapshots are written",
+				Value:   time.Hour,
+				EnvVars: []string{"BACKUP_INTERVAL"},
+			},
+			&cli.IntFlag{
+				Name:    "backup-keep",
+				Usage:   "number of scheduled snapshots to keep",
+				Value:   7,
+				EnvVars: []string{"BACKUP_KEEP"},
+			},
+		},
+		Commands: []*cli.Command{
+			{
+				Name:  "restore",
+				Usage: "replace the state file with a validated snapshot",
+				Flags: []cli.Flag{
+					&cli.PathFlag{
+						Name:     "snapshot",
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "replace an existing state file",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					err := server.RestoreSnapshot(c.Path("snapshot"), c.String("state-file"), c.Bool("force"))
+					if err != nil {
+						return fmt.Errorf("could not restore snapshot: %w", err)
+					}
+					fmt.Println("restored", c.Path("snapshot"), "to", c.String("state-file"))
+					return nil
+				},
+			},
 		},
 		Action: func(c *cli.Context) error {
 			log := zapr.NewLogger(logger)
@@ -88,13 +157,32 @@ func main() {
 			})
 
 			// api server
-			s, err := server.New(log, db, c.String("event-buffer-base-url"))
+			s, err := server.New(log, db, c.String("event-buffer-base-url"), server.Config{
+				AdminToken:             c.String("admin-token"),
+				CircuitBreakerFailures: c.Int("circuit-breaker-failures"),
+				CircuitBreakerCoolDown: c.Duration("circuit-breaker-cool-down"),
+				LookupAllowedHosts:     c.StringSlice("lookup-allowed-host"),
+				DryRunTimeout:          c.Duration("dry-run-timeout"),
+			})
 			if err != nil {
 				return fmt.Errorf("could not start server: %w", err)
 			}
 
 			eg.Go(runHttp(ctx, log, c.String("api-addr"), "api", s))
 
+			// scheduled backups
+			if c.IsSet("backup-dir") {
+				if c.Int("backup-keep") < 1 {
+					return errors.New("backup-keep must be at least 1")
+				}
+				if c.Duration("backup-interval") <= 0 {
+					return errors.New("backup-interval must be positive")
+				}
+				eg.Go(func() error {
+					return server.RunBackups(ctx, log, db, c.Path("backup-dir"), c.Duration("backup-interval"), c.Int("backup-keep"))
+				})
+			}
+
 			// run metrics server
 			metricsRouter := mux.NewRouter()
 			metricsRouter.Methods("GET").Path("/metrics").Handler(promhttp.Handler())
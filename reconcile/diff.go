@@ -0,0 +1,73 @@
+package reconcile
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/draganm/event-tap/data"
+)
+
+// Diff lists the fields that differ between the JSON representations of old
+// and new. Nested fields are separated by dots, nil stands for no value.
+func Diff(old, new any) ([]data.OptionChange, error) {
+	o, err := toJSONValue(old)
+	if err != nil {
+		return nil, err
+	}
+	n, err := toJSONValue(new)
+	if err != nil {
+		return nil, err
+	}
+	changes := []data.OptionChange{}
+	diffValues("", o, n, &changes)
+	return changes, nil
+}
+
+func toJSONValue(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	d, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal options: %w", err)
+	}
+	var jv any
+	err = json.Unmarshal(d, &jv)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal options: %w", err)
+	}
+	return jv, nil
+}
+
+func diffValues(field string, old, new any, changes *[]data.OptionChange) {
+	om, oldIsMap := old.(map[string]any)
+	nm, newIsMap := new.(map[string]any)
+	if oldIsMap || newIsMap {
+		keys := map[string]bool{}
+		for k := range om {
+			keys[k] = true
+		}
+		for k := range nm {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			f := k
+			if field != "" {
+				f = field + "." + k
+			}
+			diffValues(f, om[k], nm[k], changes)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		*changes = append(*changes, data.OptionChange{Field: field, Old: old, New: new})
+	}
+}
@@ -0,0 +1,90 @@
+// Package reconcile plans the changes that turn the installed taps of a
+// namespace into the desired ones. It is used by the apply endpoint of the
+// server as well as by clients applying changes one by one.
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/draganm/event-tap/data"
+	"sigs.k8s.io/yaml"
+)
+
+var ErrAmbiguousName = errors.New("ambiguous tap name")
+
+// Installed is a tap as it is installed on the server.
+type Installed struct {
+	ID      string
+	Options data.TapOptions
+}
+
+// Plan matches desired and installed taps by name. Desired taps that are not
+// installed are created, installed ones with different options are updated.
+// Installed taps that are not desired are only deleted when pruning.
+func Plan(desired []data.TapOptions, installed []Installed, prune bool) ([]data.ApplyAction, error) {
+	byName := map[string]Installed{}
+	for _, in := range installed {
+		_, found := byName[in.Options.Name]
+		if found {
+			return nil, fmt.Errorf("%w: more than one tap is named %q", ErrAmbiguousName, in.Options.Name)
+		}
+		byName[in.Options.Name] = in
+	}
+
+	actions := []data.ApplyAction{}
+	seen := map[string]bool{}
+	for _, d := range desired {
+		if d.Name == "" {
+			return nil, errors.New("desired taps need a name")
+		}
+		if seen[d.Name] {
+			return nil, fmt.Errorf("%w: %q is desired more than once", ErrAmbiguousName, d.Name)
+		}
+		seen[d.Name] = true
+
+		in, found := byName[d.Name]
+		if !found {
+			actions = append(actions, data.ApplyAction{Action: data.ApplyCreate, Name: d.Name})
+			continue
+		}
+
+		changes, err := Diff(in.Options, d)
+		if err != nil {
+			return nil, err
+		}
+
+		action := data.ApplyUnchanged
+		if len(changes) > 0 {
+			action = data.ApplyUpdate
+		}
+
+		actions = append(actions, data.ApplyAction{Action: action, Name: d.Name, ID: in.ID, Changes: changes})
+	}
+
+	if prune {
+		for _, in := range installed {
+			if !seen[in.Options.Name] {
+				actions = append(actions, data.ApplyAction{Action: data.ApplyDelete, Name: in.Options.Name, ID: in.ID})
+			}
+		}
+	}
+
+	return actions, nil
+}
+
+// File is the format of declarative tap configuration.
+type File struct {
+	Taps []data.TapOptions `json:"taps"`
+}
+
+// ParseFile reads YAML or JSON tap configuration. Field names are the ones of
+// the JSON API, unknown fields are rejected.
+func ParseFile(d []byte) ([]data.TapOptions, error) {
+	f := File{}
+	err := yaml.UnmarshalStrict(d, &f)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse tap configuration: %w", err)
+	}
+	return f.Taps, nil
+}
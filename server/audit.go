@@ -0,0 +1,167 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/draganm/event-tap/data"
+	"github.com/draganm/event-tap/reconcile"
+)
+
+var auditPath = dbpath.ToPath("audit")
+
+// auditKey formats sequence numbers so they sort in the order of appending.
+func auditKey(seq uint64) string {
+	return fmt.Sprintf("%020d", seq)
+}
+
+// appendAudit records a change to a tap in the same transaction as the change.
+// old and new are the options before and after the change, nil if there are none.
+func appendAudit(tx bolted.SugaredWriteTx, r *http.Request, action string, ref tapRef, old, new any) error {
+	seq := uint64(1)
+	it := tx.Iterator(auditPath)
+	it.Last()
+	if !it.IsDone() {
+		last, err := strconv.ParseUint(it.GetKey(), 10, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse audit key %s: %w", it.GetKey(), err)
+		}
+		seq = last + 1
+	}
+
+	changes, err := reconcile.Diff(old, new)
+	if err != nil {
+		return err
+	}
+
+	entry := data.AuditEntry{
+		Seq:           seq,
+		Time:          time.Now().UTC(),
+		Action:        action,
+		Namespace:     ref.namespace,
+		TapID:         ref.id,
+		ClientAddress: r.RemoteAddr,
+		ForwardedFor:  r.Header.Get("x-forwarded-for"),
+		Changes:       changes,
+	}
+
+	p := principalFromContext(r.Context())
+	if p != nil {
+		entry.ActorTokenID = p.TokenID
+		entry.ActorName = p.Name
+	}
+
+	d, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("could not marshal audit entry: %w", err)
+	}
+
+	tx.Put(auditPath.Append(auditKey(seq)), d)
+	return nil
+}
+
+func (s *Server) audit(w http.ResponseWriter, r *http.Request) {
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path)
+
+	q := r.URL.Query()
+
+	var since, until time.Time
+	if v := q.Get("since"); v != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, fmt.Errorf("could not parse since: %w", err).Error(), http.StatusBadRequest)
+			log.Error(err, "could not parse since")
+			return
+		}
+	}
+
+	if v := q.Get("until"); v != "" {
+		var err error
+		until, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, fmt.Errorf("could not parse until: %w", err).Error(), http.StatusBadRequest)
+			log.Error(err, "could not parse until")
+			return
+		}
+	}
+
+	after := uint64(0)
+	if v := q.Get("after"); v != "" {
+		var err error
+		after, err = strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, fmt.Errorf("could not parse after: %w", err).Error(), http.StatusBadRequest)
+			log.Error(err, "could not parse after")
+			return
+		}
+	}
+
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, fmt.Errorf("could not parse limit: %w", err).Error(), http.StatusBadRequest)
+			log.Error(err, "could not parse limit")
+			return
+		}
+	}
+
+	if limit <= 0 || limit > 1000 {
+		http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+		return
+	}
+
+	tapID := q.Get("tap")
+	namespace := q.Get("namespace")
+
+	p := principalFromContext(r.Context())
+
+	auditLog := data.AuditLog{Entries: []data.AuditEntry{}}
+
+	err := bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) error {
+		it := tx.Iterator(auditPath)
+		if after > 0 {
+			it.Seek(auditKey(after + 1))
+		}
+		for ; !it.IsDone() && len(auditLog.Entries) < limit; it.Next() {
+			e := data.AuditEntry{}
+			err := json.Unmarshal(it.GetValue(), &e)
+			if err != nil {
+				return fmt.Errorf("could not parse audit entry %s: %w", it.GetKey(), err)
+			}
+
+			switch {
+			case !until.IsZero() && e.Time.After(until):
+				// entries are in time order, none of the following can match
+				return nil
+			case !since.IsZero() && e.Time.Before(since):
+				continue
+			case tapID != "" && e.TapID != tapID:
+				continue
+			case namespace != "" && e.Namespace != namespace:
+				continue
+			case !p.allows(e.Namespace):
+				continue
+			}
+
+			auditLog.Entries = append(auditLog.Entries, e)
+		}
+		return nil
+	})
+
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not read audit log: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not read audit log")
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(auditLog)
+}
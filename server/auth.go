@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/draganm/event-tap/data"
+)
+
+var tokensPath = dbpath.ToPath("tokens")
+
+// tokenPrefix starts every token, so leaked tokens are easy to recognize.
+const tokenPrefix = "et_"
+
+var errUnauthorized = errors.New("unauthorized")
+
+var ErrForbidden = errors.New("forbidden")
+
+// storedToken is a token as stored in the db, only the hash of the secret is kept.
+type storedToken struct {
+	Name       string    `json:"name"`
+	Scope      string    `json:"scope"`
+	Namespaces []string  `json:"namespaces,omitempty"`
+	Hash       string    `json:"hash"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// scopeRank orders the scopes, a scope includes all scopes of lower rank.
+var scopeRank = map[string]int{
+	data.ScopeRead:  1,
+	data.ScopeWrite: 2,
+	data.ScopeAdmin: 3,
+}
+
+// principal is whoever made an authenticated request.
+type principal struct {
+	TokenID    string
+	Name       string
+	Scope      string
+	Namespaces []string
+}
+
+// allows reports whether the principal may access the namespace. Admins and
+// tokens not bound to namespaces may access all of them, as can everyone
+// when authentication is disabled.
+func (p *principal) allows(ns string) bool {
+	if p == nil || p.Scope == data.ScopeAdmin || len(p.Namespaces) == 0 {
+		return true
+	}
+	for _, n := range p.Namespaces {
+		if n == ns {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKeyType string
+
+const principalKey principalKeyType = "principal"
+
+// principalFromContext returns who made the request, nil when authentication is disabled.
+func principalFromContext(ctx context.Context) *principal {
+	p, _ := ctx.Value(principalKey).(*principal)
+	return p
+}
+
+func hashSecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
+
+// newToken creates a token `et_<id>_<secret>` with random id and secret.
+func newToken() (string, string, error) {
+	b := make([]byte, 8+32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", "", fmt.Errorf("could not generate token: %w", err)
+	}
+	id := hex.EncodeToString(b[:8])
+	secret := hex.EncodeToString(b[8:])
+	return id, tokenPrefix + id + "_" + secret, nil
+}
+
+// cutToken splits a token into its id and secret.
+func cutToken(token string) (string, string, bool) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return "", "", false
+	}
+	id, secret, found := strings.Cut(strings.TrimPrefix(token, tokenPrefix), "_")
+	if !found || id == "" || secret == "" {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+// authRequired reports whether requests must be authenticated. This is only
+// the case when an admin token is configured, stored tokens can neither turn
+// authentication on nor, once the last one is revoked, off.
+func (s *Server) authRequired() bool {
+	return s.adminToken != ""
+}
+
+// authenticate resolves the bearer token of the request.
+func (s *Server) authenticate(r *http.Request) (*principal, error) {
+	auth := r.Header.Get("authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil, errUnauthorized
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+
+	if s.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1 {
+		return &principal{Name: "admin", Scope: data.ScopeAdmin}, nil
+	}
+
+	id, secret, ok := cutToken(token)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	st := storedToken{}
+	err := bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) error {
+		tokenPath := tokensPath.Append(id)
+		if !tx.Exists(tokenPath) {
+			return errUnauthorized
+		}
+		return json.Unmarshal(tx.Get(tokenPath), &st)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(st.Hash)) != 1 {
+		return nil, errUnauthorized
+	}
+
+	return &principal{TokenID: id, Name: st.Name, Scope: st.Scope, Namespaces: st.Namespaces}, nil
+}
+
+// requireScope only passes requests with a token that has at least the given scope.
+func (s *Server) requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := s.log.WithValues("method", r.Method, "path", r.URL.Path)
+
+		if !s.authRequired() {
+			next(w, r)
+			return
+		}
+
+		p, err := s.authenticate(r)
+		if errors.Is(err, errUnauthorized) {
+			w.Header().Set("www-authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, fmt.Errorf("could not authenticate: %w", err).Error(), http.StatusInternalServerError)
+			log.Error(err, "could not authenticate")
+			return
+		}
+
+		if scopeRank[p.Scope] < scopeRank[scope] {
+			http.Error(w, fmt.Sprintf("token needs the %s scope", scope), http.StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), principalKey, p)))
+	}
+}
@@ -0,0 +1,309 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/draganm/bolted/embedded"
+	"github.com/draganm/event-tap/data"
+	"github.com/go-logr/logr"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	backupPrefix = "state-"
+	backupSuffix = ".bolt"
+	// nanoseconds keep snapshots written within the same second apart, the
+	// fixed width keeps the names sorting chronologically
+	backupTimeFormat = "20060102T150405.000000000Z"
+)
+
+// backup streams a snapshot of the state database. The snapshot is taken
+// within one read transaction, so taps keep running while it is written.
+func (s *Server) backup(w http.ResponseWriter, r *http.Request) {
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path)
+
+	name := backupPrefix + time.Now().UTC().Format(backupTimeFormat) + backupSuffix
+
+	cw := &countingWriter{w: w}
+	err := bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) error {
+		w.Header().Set("content-type", "application/octet-stream")
+		w.Header().Set("content-disposition", fmt.Sprintf("attachment; filename=%q", name))
+		tx.Dump(cw)
+		return nil
+	})
+
+	if err != nil && cw.n == 0 {
+		http.Error(w, fmt.Errorf("could not write backup: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not write backup")
+		return
+	}
+
+	if err != nil {
+		// the response is already on its way, aborting it tells the client
+		// the snapshot is incomplete instead of ending it as if it was whole
+		log.Error(err, "could not write backup", "written", cw.n)
+		panic(http.ErrAbortHandler)
+	}
+
+	log.Info("backup written", "bytes", cw.n)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// WriteBackup writes a snapshot of the database into the directory and
+// returns its path. The snapshot only gets its final name once it is
+// completely written.
+func WriteBackup(db bolted.Database, dir string) (string, error) {
+	name := filepath.Join(dir, backupPrefix+time.Now().UTC().Format(backupTimeFormat)+backupSuffix)
+
+	f, err := os.CreateTemp(dir, ".backup-*")
+	if err != nil {
+		return "", fmt.Errorf("could not create backup file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	err = bolted.SugaredRead(db, func(tx bolted.SugaredReadTx) error {
+		tx.Dump(f)
+		return nil
+	})
+	if err != nil {
+		f.Close()
+		return "", fmt.Errorf("could not dump database: %w", err)
+	}
+
+	err = f.Sync()
+	if err != nil {
+		f.Close()
+		return "", fmt.Errorf("could not sync backup file: %w", err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return "", fmt.Errorf("could not close backup file: %w", err)
+	}
+
+	err = os.Rename(f.Name(), name)
+	if err != nil {
+		return "", fmt.Errorf("could not rename backup file: %w", err)
+	}
+
+	return name, nil
+}
+
+// RotateBackups removes all but the newest keep snapshots from the directory.
+func RotateBackups(dir string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("could not list backups: %w", err)
+	}
+
+	backups := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), backupPrefix) && strings.HasSuffix(e.Name(), backupSuffix) {
+			backups = append(backups, e.Name())
+		}
+	}
+
+	// the timestamp in the name sorts chronologically
+	sort.Strings(backups)
+
+	for len(backups) > keep {
+		err = os.Remove(filepath.Join(dir, backups[0]))
+		if err != nil {
+			return fmt.Errorf("could not remove old backup: %w", err)
+		}
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+// RunBackups writes a snapshot into the directory at every interval and keeps
+// the newest ones, until the context is done.
+func RunBackups(ctx context.Context, log logr.Logger, db bolted.Database, dir string, interval time.Duration, keep int) error {
+	log = log.WithValues("dir", dir)
+
+	if interval <= 0 {
+		return fmt.Errorf("backup interval must be positive, got %s", interval)
+	}
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return fmt.Errorf("could not create backup dir: %w", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		name, err := WriteBackup(db, dir)
+		if err != nil {
+			log.Error(err, "scheduled backup failed")
+			continue
+		}
+		log.Info("scheduled backup written", "file", name)
+
+		err = RotateBackups(dir, keep)
+		if err != nil {
+			log.Error(err, "could not rotate backups")
+		}
+	}
+}
+
+// ValidateSnapshot checks that the file is an intact database holding taps
+// this server can start.
+func ValidateSnapshot(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	bdb, err := bbolt.Open(path, 0600, &bbolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("could not open snapshot: %w", err)
+	}
+
+	err = bdb.View(func(tx *bbolt.Tx) error {
+		// pages beyond the end of the file can't be checked without crashing
+		if tx.Size() > fi.Size() {
+			return fmt.Errorf("snapshot is truncated, it has %d of %d bytes", fi.Size(), tx.Size())
+		}
+		for err := range tx.Check() {
+			return fmt.Errorf("snapshot is corrupt: %w", err)
+		}
+		return nil
+	})
+	bdb.Close()
+	if err != nil {
+		return err
+	}
+
+	db, err := embedded.Open(path, 0600, embedded.Options{Options: bbolt.Options{ReadOnly: true, Timeout: time.Second}})
+	if err != nil {
+		return fmt.Errorf("snapshot is not a state database: %w", err)
+	}
+	defer db.Close()
+
+	return bolted.SugaredRead(db, func(tx bolted.SugaredReadTx) error {
+		switch {
+		case tx.Exists(namespacesPath):
+			for nsIt := tx.Iterator(namespacesPath); !nsIt.IsDone(); nsIt.Next() {
+				ns := nsIt.GetKey()
+				if !tx.Exists(tapsPathOf(ns)) {
+					return fmt.Errorf("namespace %s has no taps", ns)
+				}
+				for it := tx.Iterator(tapsPathOf(ns)); !it.IsDone(); it.Next() {
+					_, err := readTapOptions(tx, tapRef{namespace: ns, id: it.GetKey()})
+					if err != nil {
+						return fmt.Errorf("invalid tap %s/%s: %w", ns, it.GetKey(), err)
+					}
+				}
+			}
+			return nil
+		case tx.Exists(legacyTapsPath):
+			for it := tx.Iterator(legacyTapsPath); !it.IsDone(); it.Next() {
+				err := validateOptions(tx, legacyTapsPath.Append(it.GetKey(), "options"))
+				if err != nil {
+					return fmt.Errorf("invalid tap %s: %w", it.GetKey(), err)
+				}
+			}
+			return nil
+		default:
+			return errors.New("snapshot holds no taps")
+		}
+	})
+}
+
+func validateOptions(tx bolted.SugaredReadTx, path dbpath.Path) error {
+	if !tx.Exists(path) {
+		return ErrNotFound
+	}
+	return json.Unmarshal(tx.Get(path), &data.TapOptions{})
+}
+
+// RestoreSnapshot replaces the state file with the snapshot after validating
+// a copy of it. An existing state file is only replaced when force is set.
+func RestoreSnapshot(snapshot, stateFile string, force bool) error {
+	if !force {
+		_, err := os.Stat(stateFile)
+		if err == nil {
+			return fmt.Errorf("state file %s already exists", stateFile)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	tmp := stateFile + ".restore-tmp"
+	err := copyFile(snapshot, tmp)
+	if err != nil {
+		return fmt.Errorf("could not copy snapshot: %w", err)
+	}
+	defer os.Remove(tmp)
+
+	err = ValidateSnapshot(tmp)
+	if err != nil {
+		return fmt.Errorf("invalid snapshot: %w", err)
+	}
+
+	err = os.Rename(tmp, stateFile)
+	if err != nil {
+		return fmt.Errorf("could not replace state file: %w", err)
+	}
+
+	return nil
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(to, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, src)
+	if err != nil {
+		dst.Close()
+		return err
+	}
+
+	err = dst.Sync()
+	if err != nil {
+		dst.Close()
+		return err
+	}
+
+	return dst.Close()
+}
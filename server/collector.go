@@ -24,16 +24,18 @@ var (
 	tapsCount = prometheus.NewDesc(
 		"taps_count",
 		"Number of event taps installed.",
-		nil, nil,
+		[]string{"namespace"}, nil,
 	)
 )
 
 func (sc *statsCollector) Collect(ch chan<- prometheus.Metric) {
 
-	var messagesCount float64
+	counts := map[string]float64{}
 
 	err := bolted.SugaredRead(sc.db, func(tx bolted.SugaredReadTx) error {
-		messagesCount = float64(tx.Size(tapsPath))
+		for it := tx.Iterator(namespacesPath); !it.IsDone(); it.Next() {
+			counts[it.GetKey()] = float64(tx.Size(tapsPathOf(it.GetKey())))
+		}
 		return nil
 	})
 
@@ -41,10 +43,13 @@ func (sc *statsCollector) Collect(ch chan<- prometheus.Metric) {
 		sc.log.Error(err, "could not collect metrics")
 	}
 
-	ch <- prometheus.MustNewConstMetric(
-		tapsCount,
-		prometheus.CounterValue,
-		messagesCount,
-	)
+	for ns, count := range counts {
+		ch <- prometheus.MustNewConstMetric(
+			tapsCount,
+			prometheus.CounterValue,
+			count,
+			ns,
+		)
+	}
 
 }
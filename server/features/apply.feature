@@ -0,0 +1,44 @@
+Feature: apply
+
+    Scenario: creating, updating and pruning taps
+        Given there is one tap
+        And a tap named "old"
+        When I apply with pruning:
+            """
+            taps:
+              - name: tap1
+                code: function mapEvents(evts){return evts}
+                webhook_url: WEBHOOK_URL
+                batch_limit: 20
+              - name: new
+                code: function mapEvents(evts){return evts}
+                webhook_url: WEBHOOK_URL
+                batch_limit: 20
+            """
+        Then the apply result should be "update tap1, create new, delete old"
+        And the tap names should be "new, tap1"
+
+    Scenario: applying unchanged taps
+        Given there is one tap
+        When I apply with pruning:
+            """
+            taps:
+              - name: tap1
+                code: function mapEvents(evts){return evts.map(([id, evt]) => evt)}
+                webhook_url: WEBHOOK_URL
+                batch_limit: 20
+            """
+        Then the apply result should be "unchanged tap1"
+
+    Scenario: dry run
+        Given there is one tap
+        When I dry run applying with pruning:
+            """
+            taps:
+              - name: new
+                code: function mapEvents(evts){return evts}
+                webhook_url: WEBHOOK_URL
+                batch_limit: 20
+            """
+        Then the apply result should be "create new, delete tap1"
+        And the tap names should be "tap1"
@@ -0,0 +1,23 @@
+Feature: audit log
+
+    Scenario: recording who changed a tap
+        Given an admin token
+        And a token with the "write" scope
+        When I create a tap with that token
+        And I pause the tap with that token
+        And I resume the tap with that token
+        And I delete the tap with that token
+        Then the audit log of the tap should contain "create, pause, resume, delete" by that token
+
+    Scenario: recording changed options
+        Given there is one tap
+        When I update the webhook url of the tap
+        Then the last audit entry of the tap should change "webhook_url"
+
+    Scenario: seeking a tap
+        Given one event in the buffer
+        And there is one tap
+        And the receiver should receive that event as webhook
+        When I seek the tap to the start of the buffer
+        Then the receiver should receive that event twice
+        And the last audit entry of the tap should be a "seek"
@@ -0,0 +1,49 @@
+Feature: authentication
+
+    Scenario: rejecting requests without a token
+        Given an admin token
+        When I list the taps without a token
+        Then the request should fail with status "401 Unauthorized"
+
+    Scenario: read tokens can't create taps
+        Given an admin token
+        And a token with the "read" scope
+        When I list the taps with that token
+        Then the request should succeed
+        When I create a tap with that token
+        Then the request should fail with status "403 Forbidden"
+
+    Scenario: write tokens can create taps
+        Given an admin token
+        And a token with the "write" scope
+        When I create a tap with that token
+        Then the request should succeed
+
+    Scenario: revoking a token
+        Given an admin token
+        And a token with the "write" scope
+        When I revoke that token
+        And I list the taps with that token
+        Then the request should fail with status "401 Unauthorized"
+
+    Scenario: tokens can't be created while authentication is disabled
+        When I create a token without authentication
+        Then the request should fail with status "409 Conflict"
+
+    Scenario: creating a token requires the admin token
+        Given an admin token
+        When I create a token without authentication
+        Then the request should fail with status "401 Unauthorized"
+
+    Scenario: revoking the last token keeps authentication on
+        Given an admin token
+        And a token with the "write" scope
+        When I revoke that token
+        And I list the taps without a token
+        Then the request should fail with status "401 Unauthorized"
+
+    Scenario: only admins can publish libraries
+        Given an admin token
+        And a token with the "write" scope
+        When I publish a library with that token
+        Then the request should fail with status "403 Forbidden"
@@ -0,0 +1,18 @@
+Feature: backup and restore
+
+    Scenario: restoring a backup of a running server
+        Given there is one tap
+        When I back up the server
+        Then the snapshot should be valid
+        When I restore the snapshot and start a new server on it
+        Then the new server should have the tap
+
+    Scenario: restoring a damaged snapshot
+        Given there is one tap
+        When I back up the server
+        And the snapshot is truncated
+        Then restoring the snapshot should fail
+
+    Scenario: keeping scheduled backups written within the same second
+        When I write 3 scheduled backups keeping 2
+        Then the backup directory should hold 2 snapshots
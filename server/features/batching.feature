@@ -0,0 +1,24 @@
+Feature: batching
+
+    Scenario: delivering an incomplete batch when the wait elapses
+        Given one event in the buffer
+        When I create a new map of events waiting for a batch of 3 events for at most 100ms
+        Then the receiver should receive that event as webhook
+
+    Scenario: holding back deliveries until the batch has enough events
+        Given a receiver recording batches
+        And 2 events in the buffer
+        When I create a tap delivering to that receiver in batches of at least 3 events
+        And I wait 200ms
+        Then the receiver should have received 0 requests
+        When one event in the buffer
+        Then the receiver should receive a batch of 3 events
+
+    Scenario: holding back deliveries until the batch has enough bytes
+        Given a receiver recording batches
+        And 2 events in the buffer
+        When I create a tap delivering to that receiver in batches of at least 14 bytes
+        And I wait 200ms
+        Then the receiver should have received 0 requests
+        When one event in the buffer
+        Then the receiver should receive a batch of 3 events
@@ -0,0 +1,25 @@
+Feature: circuit breakers
+
+    Scenario: an open circuit breaker stops requests to the host
+        Given circuit breakers that open after 1 failure for 60000ms
+        And a receiver failing the first 100 requests
+        And there is 1 tap delivering to that receiver
+        When one event in the buffer
+        Then the tap status should contain "is open"
+        And the receiver should have received 1 request
+
+    Scenario: a successful probe closes the circuit breaker
+        Given circuit breakers that open after 1 failure for 100ms
+        And a receiver failing the first 1 request
+        And there is 1 tap delivering to that receiver
+        When one event in the buffer
+        Then the receiver should eventually receive 2 requests
+        And the tap status should contain "running"
+
+    Scenario: a half-open circuit breaker lets one probe through
+        Given circuit breakers that open after 1 failure for 500ms
+        And a receiver failing the first 100 requests
+        And there are 2 taps delivering to that receiver
+        When one event in the buffer
+        And I wait 1300ms
+        Then the receiver should have received 3 requests
@@ -3,4 +3,14 @@ Feature: deleting taps
     Scenario: deleting an existing tap
         Given there is one tap
         When I delete the tap
-        Then the list of taps should not contain the deleted tap
\ No newline at end of file
+        Then the list of taps should not contain the deleted tap
+
+    Scenario: deleting a tap stuck in its code
+        Given there is one tap looping forever on an event
+        When I delete the tap
+        Then the list of taps should not contain the deleted tap
+
+    Scenario: deleting a tap waiting for its receiver
+        Given there is one tap delivering to a receiver that never responds
+        When I delete the tap
+        Then the list of taps should not contain the deleted tap
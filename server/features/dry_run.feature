@@ -0,0 +1,23 @@
+Feature: dry run
+
+    Scenario: testing tap code with inline events
+        Given there are no taps
+        When I test tap code logging "mapping" with the inline event "evt1"
+        Then the test output should be "evt1"
+        And the test logs should contain "mapping 1"
+        And there should be no taps
+
+    Scenario: testing tap code with events from the buffer
+        Given one event in the buffer
+        When I test tap code logging "mapping" with the events from the buffer
+        Then the test output should be "evt1"
+
+    Scenario: testing failing tap code
+        Given there are no taps
+        When I test tap code throwing "boom"
+        Then the test error should contain "boom"
+
+    Scenario: testing tap code that never returns
+        Given dry runs limited to 100ms
+        When I test tap code that never returns
+        Then the test error should contain "execution stopped: context deadline exceeded"
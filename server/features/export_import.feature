@@ -0,0 +1,34 @@
+Feature: export and import
+
+    Scenario: moving taps to another server
+        Given one event in the buffer
+        And I create a new map of events counting events in the state
+        And the receiver should receive the event with count 1
+        When I export the taps once the cursor and state are stored
+        And I import the archive into a new server
+        Then exporting the new server should give the same taps
+
+    Scenario: importing existing taps
+        Given there is one tap
+        When I export the taps
+        And I import the archive
+        Then the request should fail with status "409 Conflict"
+        When I import the archive skipping conflicts
+        Then the tap should be skipped
+
+    Scenario: importing a tap with an invalid id
+        Given there is one tap
+        When I export the taps
+        And the id of the exported tap is changed to "..hidden"
+        And I import the archive
+        Then the request should fail with status "400 Bad Request"
+
+    Scenario: moving taps requiring a library to another server
+        Given the library "decorate" version "1" decorating events with "v1"
+        And one event in the buffer
+        And I create a new map of events decorating events with the "decorate" library
+        And the receiver should receive events decorated with "v1"
+        When I export the taps
+        And I import the archive into a new server
+        Then the new server should have the library "decorate" at version "1"
+        And exporting the new server should give the same taps
@@ -0,0 +1,6 @@
+Feature: filtering events
+
+    Scenario: events rejected by filterEvent are not delivered
+        Given the events "drop" and "keep" in the buffer
+        When I create a new map of events filtering out "drop"
+        Then the receiver should only receive "keep"
@@ -0,0 +1,22 @@
+Feature: http lookups
+
+    Scenario: looking up reference data from an allowed host
+        Given one event in the buffer
+        When I create a new map of events looking up the buffer over http
+        Then the receiver should receive the event with lookup status 200
+
+    Scenario: looking up a host that is not allowed
+        When I test tap code looking up a host that is not allowed
+        Then the test error should contain "host 127.0.0.1 is not allowed"
+
+    Scenario: looking up a host that only the tap allows
+        When I test tap code looking up a host that only the tap allows
+        Then the test error should contain "host localhost is not allowed"
+
+    Scenario: following a redirect to a host that is not allowed
+        When I test tap code looking up a url redirecting to a host that is not allowed
+        Then the test error should contain "redirect to host localhost is not allowed"
+
+    Scenario: looking up a url that does not respond in time
+        When I test tap code looking up a url that does not respond in time
+        Then the test error should contain "context deadline exceeded"
@@ -0,0 +1,30 @@
+Feature: idempotent tap creation
+
+    Scenario: retrying a create with an idempotency key
+        When I create the tap "orders" with the idempotency key "deploy-1"
+        And I create the tap "orders" with the idempotency key "deploy-1"
+        Then both creates should return the same tap
+        When I list the taps
+        Then the result should have one tap
+
+    Scenario: reusing an idempotency key for another tap
+        When I create the tap "orders" with the idempotency key "deploy-1"
+        And I create the tap "payments" with the idempotency key "deploy-1"
+        Then the request should fail with status "422 Unprocessable Entity"
+
+    Scenario: retrying a create with a client-supplied id
+        When I create the tap "orders" with the id "orders-v1"
+        And I create the tap "orders" with the id "orders-v1"
+        Then both creates should return the same tap
+        When I list the taps
+        Then the result should have one tap
+
+    Scenario: reusing a client-supplied id with other options
+        When I create the tap "orders" with the id "orders-v1"
+        And I create the tap "payments" with the id "orders-v1"
+        Then the request should fail with status "409 Conflict"
+
+    Scenario: tap names are unique within a namespace
+        Given a tap named "orders"
+        When I create the tap "orders" with the id "orders-v2"
+        Then the request should fail with status "409 Conflict"
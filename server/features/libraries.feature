@@ -0,0 +1,16 @@
+Feature: libraries
+
+    Scenario: requiring a library from tap code
+        Given the library "decorate" version "1" decorating events with "v1"
+        And one event in the buffer
+        When I create a new map of events decorating events with the "decorate" library
+        Then the receiver should receive events decorated with "v1"
+
+    Scenario: restarting taps tracking the latest library version
+        Given the library "decorate" version "1" decorating events with "v1"
+        And one event in the buffer
+        And I create a new map of events decorating events with the "decorate" library
+        And the receiver should receive events decorated with "v1"
+        When the library "decorate" version "2" decorating events with "v2"
+        And one event in the buffer
+        Then the receiver should receive events decorated with "v1,v2"
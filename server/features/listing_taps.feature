@@ -8,4 +8,27 @@ Feature: Feature name
     Scenario: listing one tap
         Given there is one tap
         When I list the taps
-        Then the result should have one tap
\ No newline at end of file
+        Then the result should have one tap
+
+    Scenario: selecting taps by label
+        Given a tap named "invoices" labelled "team=billing,env=prod"
+        And a tap named "invoices-dev" labelled "team=billing,env=dev"
+        And a tap named "signups" labelled "team=growth,env=prod"
+        When I list the taps selecting "team=billing,env!=dev"
+        Then the listed taps should be "invoices"
+        When I list the taps selecting "env,!team"
+        Then the listed taps should be ""
+
+    Scenario: filtering taps by status and name
+        Given a tap named "invoices" labelled "team=billing"
+        And a tap named "invoices-dev" labelled "team=billing"
+        And a tap named "signups" labelled "team=growth"
+        And the tap named "invoices-dev" is paused
+        When I list the paused taps
+        Then the listed taps should be "invoices-dev"
+        When I list the taps with names starting with "inv"
+        Then the listed taps should be "invoices, invoices-dev"
+
+    Scenario: listing with an invalid selector
+        When I list the taps selecting "team=bill ing"
+        Then the request should fail with status "400 Bad Request"
@@ -0,0 +1,33 @@
+Feature: namespaces
+
+    Scenario: taps are isolated between namespaces
+        Given there is one tap in the namespace "team-a"
+        When I list the taps of the namespace "team-b"
+        Then the result should be empty
+        When I list the taps of the namespace "team-a"
+        Then the result should have one tap
+
+    Scenario: taps without a namespace are in the default namespace
+        Given there is one tap
+        When I list the taps of the namespace "default"
+        Then the result should have one tap
+
+    Scenario: tokens bound to a namespace
+        Given an admin token
+        And a token with the "write" scope bound to the namespace "team-a"
+        When I list the taps of the namespace "team-b" with that token
+        Then the request should fail with status "403 Forbidden"
+        When I list the taps of the namespace "team-a" with that token
+        Then the request should succeed
+
+    Scenario: limiting the number of taps in a namespace
+        Given the namespace "team-a" is limited to 1 tap
+        And there is one tap in the namespace "team-a"
+        When I create a tap in the namespace "team-a"
+        Then the request should fail with status "403 Forbidden"
+
+    Scenario: sharing the request quota of a namespace
+        Given the namespace "team-a" is limited to 2 requests per second
+        And there are 3 taps in the namespace "team-a"
+        And one event in the buffer
+        Then the receiver should receive 3 webhooks spread over at least 400 milliseconds
@@ -0,0 +1,24 @@
+Feature: rate limiting
+
+    Scenario: changing the rate limit of a running tap
+        Given there is one tap
+        When I limit the tap to 100 events per second
+        And one event in the buffer
+        Then the receiver should receive that event as webhook
+
+    Scenario: changing the rate limit of a missing tap
+        Given there are no taps
+        When I limit a missing tap
+        Then the request should fail
+
+    Scenario: spacing deliveries under a low rate limit
+        When I create a tap named "slow-tap" limited to 5 events per second
+        And 8 events in the buffer
+        Then the receiver should receive 8 webhooks spread over at least 500 milliseconds
+        And the tap named "slow-tap" should have waited at least 500 milliseconds for its rate limit
+
+    Scenario: spacing deliveries after lowering the rate limit of a running tap
+        Given there is one tap
+        When I limit the tap to 5 events per second
+        And 8 events in the buffer
+        Then the receiver should receive 8 webhooks spread over at least 500 milliseconds
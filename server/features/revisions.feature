@@ -0,0 +1,37 @@
+Feature: optimistic concurrency
+
+    Scenario: updating a tap at its current revision
+        Given there is one tap
+        When I read the tap with its revision
+        And I update the webhook url of the tap at that revision
+        Then the request should succeed
+        And the tap should be at revision 2
+
+    Scenario: updating a tap someone else changed
+        Given there is one tap
+        When I read the tap with its revision
+        And I update the webhook url of the tap
+        And I update the webhook url of the tap at that revision
+        Then the request should fail with status "412 Precondition Failed"
+
+    Scenario: deleting a tap someone else changed
+        Given there is one tap
+        When I read the tap with its revision
+        And I limit the tap to 10 events per second
+        And I delete the tap at that revision
+        Then the request should fail with status "412 Precondition Failed"
+        And the tap should be at revision 2
+
+    Scenario: rolling back a tap
+        Given there is one tap
+        When I update the webhook url of the tap
+        And I roll the tap back to revision 1
+        Then the request should succeed
+        And the tap should have 3 revisions
+        And revision 3 of the tap should have the options of revision 1
+        And the last audit entry of the tap should be a "rollback"
+
+    Scenario: rolling back to a missing revision
+        Given there is one tap
+        When I roll the tap back to revision 7
+        Then the request should fail with status "404 Not Found"
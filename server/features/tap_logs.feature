@@ -0,0 +1,22 @@
+Feature: tap logs
+
+    Scenario: reading console output of a tap
+        Given one event in the buffer
+        When I create a new map of events logging "mapping" to the console
+        Then the receiver should receive that event as webhook
+        And the logs of the tap should contain "mapping 1"
+
+    Scenario: reading console output of a tap after it restarted
+        Given one event in the buffer
+        When I create a new map of events logging "mapping" to the console
+        Then the receiver should receive that event as webhook
+        When I pause and resume the tap
+        Then the logs of the tap should contain "mapping 1"
+
+    Scenario: reading console output of a paused tap that did not run
+        Given one event in the buffer
+        When I create a new map of events logging "mapping" to the console
+        And I pause the tap
+        And I back up the server
+        And I restore the snapshot and start a new server on it
+        Then the logs of the tap on the new server should be empty
@@ -0,0 +1,6 @@
+Feature: tap state
+
+    Scenario: keeping a counter in the tap state
+        Given one event in the buffer
+        When I create a new map of events counting events in the state
+        Then the receiver should receive the event with count 1
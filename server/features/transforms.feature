@@ -0,0 +1,16 @@
+Feature: transforms
+
+    Scenario: mapping events with jq
+        Given one event in the buffer
+        When I create a new map of events using the jq transform "map(.[1])"
+        Then the receiver should receive that event as webhook
+
+    Scenario: mapping events with cel
+        Given one event in the buffer
+        When I create a new map of events using the cel transform "events.map(e, e[1])"
+        Then the receiver should receive that event as webhook
+
+    Scenario: rejecting an invalid jq program
+        Given there are no taps
+        When I create a new map of events using the jq transform "map(.[1]"
+        Then the request should fail
@@ -0,0 +1,16 @@
+Feature: typescript and es modules
+
+    Scenario: mapping events with typescript code
+        Given one event in the buffer
+        When I create a new map of events written in typescript
+        Then the receiver should receive that event as webhook
+
+    Scenario: rejecting code that can't be transpiled
+        Given there are no taps
+        When I create a new map of events with invalid typescript
+        Then the request should fail
+
+    Scenario: reporting errors at the line of the typescript code
+        Given one event in the buffer
+        When I create a new map of events written in typescript throwing on line 9
+        Then the tap status should contain "tap.ts:9:"
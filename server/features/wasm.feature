@@ -0,0 +1,11 @@
+Feature: wasm transforms
+
+    Scenario: mapping events with a wasm module
+        Given one event in the buffer
+        When I create a new map of events using a wasm module echoing its input
+        Then the receiver should receive that event paired with its id
+
+    Scenario: rejecting an invalid wasm module
+        Given there are no taps
+        When I create a new map of events using an invalid wasm module
+        Then the request should fail
@@ -0,0 +1,11 @@
+Feature: webhook url templates
+
+    Scenario: resolving the webhook url from mapped events
+        Given one event in the buffer
+        When I create a new map of events with a templated webhook url
+        Then the receiver should receive the mapped event on the resolved url
+
+    Scenario: escaping values inserted into the webhook url
+        Given one event in the buffer
+        When I create a new map of events with a templated webhook url and the tenant "a/b?admin=1&c d"
+        Then the receiver should be called on "/tenants/a%2Fb%3Fadmin%3D1%26c%20d/events"
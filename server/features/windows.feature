@@ -0,0 +1,26 @@
+Feature: windowed aggregation
+
+    Scenario: emitting an event time window once the watermark passes its end
+        Given events with event times 100 and 1500 in the buffer
+        When I create a new map of events counting events in tumbling windows of 1000ms
+        Then the receiver should receive the window from 0 to 1000 with count 1
+
+    Scenario: emitting a wall clock window
+        Given one event in the buffer
+        When I create a new map of events counting events in wall clock windows of 100ms
+        Then the receiver should receive a wall clock window with count 1
+
+    Scenario: emitting overlapping sliding windows
+        Given events with event times 100, 700 and 2600 in the buffer
+        When I create a new map of events counting events in sliding windows of 1000ms every 500ms
+        Then the receiver should receive the windows
+            | start | end  | count |
+            | -500  | 500  | 1     |
+            | 0     | 1000 | 2     |
+            | 500   | 1500 | 1     |
+
+    Scenario: accepting late events within the allowed lateness
+        Given events with event times 100, 1200, 400, 1800 and 200 in the buffer
+        When I create a tap named "late-tap" counting events in tumbling windows of 1000ms allowing 500ms of lateness
+        Then the receiver should receive the window from 0 to 1000 with count 2
+        And the tap named "late-tap" should have dropped 1 late event
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/event-tap/data"
+	"github.com/draganm/event-tap/reconcile"
+	"github.com/draganm/event-tap/server/tap"
+	"github.com/gofrs/uuid"
+)
+
+var errInvalidPlan = errors.New("invalid plan")
+
+// listInstalled returns the taps of a namespace with their options.
+func listInstalled(tx bolted.SugaredReadTx, ns string) ([]reconcile.Installed, error) {
+	installed := []reconcile.Installed{}
+	for it := tx.Iterator(tapsPathOf(ns)); !it.IsDone(); it.Next() {
+		opts, err := readTapOptions(tx, tapRef{namespace: ns, id: it.GetKey()})
+		if err != nil {
+			return nil, err
+		}
+		installed = append(installed, reconcile.Installed{ID: it.GetKey(), Options: opts})
+	}
+	return installed, nil
+}
+
+// apply makes the taps of the namespace match the desired ones in a single transaction.
+func (s *Server) apply(w http.ResponseWriter, r *http.Request) {
+	ns := namespaceOf(r)
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path, "namespace", ns)
+
+	req := data.ApplyRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not decode apply request: %w", err).Error(), http.StatusBadRequest)
+		log.Error(err, "could not decode apply request")
+		return
+	}
+
+	desired := map[string]data.TapOptions{}
+	transpiled := map[string]*tap.Transpiled{}
+	for _, opts := range req.Taps {
+		tr, err := prepareTap(opts)
+		if err != nil {
+			err = fmt.Errorf("tap %q: %w", opts.Name, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			log.Error(err, "could not prepare tap")
+			return
+		}
+		desired[opts.Name] = opts
+		transpiled[opts.Name] = tr
+	}
+
+	var actions []data.ApplyAction
+	toStart := []tapRef{}
+	toRestart := []tapRef{}
+	toStop := []tapRef{}
+
+	err = bolted.SugaredWrite(s.db, func(tx bolted.SugaredWriteTx) error {
+		ensureNamespace(tx, ns)
+
+		installed, err := listInstalled(tx, ns)
+		if err != nil {
+			return err
+		}
+
+		actions, err = reconcile.Plan(req.Taps, installed, req.Prune)
+		if err != nil {
+			return fmt.Errorf("%w: %s", errInvalidPlan, err)
+		}
+
+		q, err := readQuota(tx, ns)
+		if err != nil {
+			return err
+		}
+
+		count := len(installed)
+		for _, a := range actions {
+			switch a.Action {
+			case data.ApplyCreate:
+				count++
+			case data.ApplyDelete:
+				count--
+			}
+		}
+
+		if q.MaxTaps > 0 && count > q.MaxTaps {
+			return fmt.Errorf("%w: namespace %s is limited to %d taps", ErrQuotaExceeded, ns, q.MaxTaps)
+		}
+
+		if req.DryRun {
+			return nil
+		}
+
+		for i, a := range actions {
+			switch a.Action {
+			case data.ApplyCreate:
+				id, err := uuid.NewV6()
+				if err != nil {
+					return fmt.Errorf("could not create tap id: %w", err)
+				}
+				ref := tapRef{namespace: ns, id: id.String()}
+				tx.CreateMap(ref.path())
+				err = putTap(tx, ref.path(), desired[a.Name], transpiled[a.Name])
+				if err != nil {
+					return err
+				}
+				err = appendAudit(tx, r, data.AuditCreate, ref, nil, desired[a.Name])
+				if err != nil {
+					return err
+				}
+				actions[i].ID = ref.id
+				toStart = append(toStart, ref)
+			case data.ApplyUpdate:
+				ref := tapRef{namespace: ns, id: a.ID}
+				old, err := readTapOptions(tx, ref)
+				if err != nil {
+					return err
+				}
+				err = putTap(tx, ref.path(), desired[a.Name], transpiled[a.Name])
+				if err != nil {
+					return err
+				}
+				err = appendAudit(tx, r, data.AuditUpdate, ref, old, desired[a.Name])
+				if err != nil {
+					return err
+				}
+				if !isPaused(tx, ref) {
+					toRestart = append(toRestart, ref)
+				}
+			case data.ApplyDelete:
+				ref := tapRef{namespace: ns, id: a.ID}
+				old, err := readTapOptions(tx, ref)
+				if err != nil {
+					return err
+				}
+				tx.Delete(ref.path())
+				err = appendAudit(tx, r, data.AuditDelete, ref, old, nil)
+				if err != nil {
+					return err
+				}
+				toStop = append(toStop, ref)
+			}
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, errInvalidPlan) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		log.Error(err, "invalid plan")
+		return
+	}
+
+	if errors.Is(err, ErrQuotaExceeded) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		log.Error(err, "quota exceeded")
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not apply taps: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not apply taps")
+		return
+	}
+
+	for _, ref := range toStop {
+		s.forgetTap(ref)
+	}
+
+	for _, ref := range toRestart {
+		err = s.restartTap(log, ref)
+		if err != nil {
+			log.Error(err, "could not restart tap", "tapID", ref.id)
+		}
+	}
+
+	for _, ref := range toStart {
+		err = s.startTap(log, ref)
+		if err != nil {
+			log.Error(err, "could not start tap", "tapID", ref.id)
+		}
+	}
+
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(data.ApplyResult{Actions: actions})
+}
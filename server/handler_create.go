@@ -1,80 +1,276 @@
 package server
 
 import (
-	"context"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"regexp"
+	"time"
 
 	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
 	"github.com/draganm/event-tap/data"
 	"github.com/draganm/event-tap/server/tap"
 	"github.com/gofrs/uuid"
 )
 
-type createTapOptions data.TapOptions
 type createTapResponse data.TapID
 
+var errInvalidTap = errors.New("invalid tap")
+
+var tapIDRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,127}$`)
+
+// prepareTap validates the options and transpiles the code of JavaScript transforms.
+func prepareTap(opts data.TapOptions) (*tap.Transpiled, error) {
+	err := validateLabels(opts.Labels)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errInvalidTap, err)
+	}
+
+	err = tap.ValidateTransform(opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid transform: %s", errInvalidTap, err)
+	}
+
+	if opts.Transform != "" && opts.Transform != tap.TransformJavaScript {
+		return nil, nil
+	}
+
+	transpiled, err := tap.Transpile(opts.Language, opts.Code)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not transpile code: %s", errInvalidTap, err)
+	}
+
+	return transpiled, nil
+}
+
+// putTap stores the options of a tap together with its transpiled code as
+// the next revision of the tap.
+func putTap(tx bolted.SugaredWriteTx, tapPath dbpath.Path, opts data.TapOptions, transpiled *tap.Transpiled) error {
+	tcd, err := json.Marshal(opts)
+	if err != nil {
+		return fmt.Errorf("could not marshal tap config: %w", err)
+	}
+
+	err = nextRevision(tx, tapPath, opts)
+	if err != nil {
+		return err
+	}
+
+	tx.Put(tapPath.Append("options"), tcd)
+
+	for _, p := range []dbpath.Path{tapPath.Append("transpiled"), tapPath.Append("source_map")} {
+		if tx.Exists(p) {
+			tx.Delete(p)
+		}
+	}
+
+	if transpiled != nil {
+		tx.Put(tapPath.Append("transpiled"), []byte(transpiled.Code))
+		tx.Put(tapPath.Append("source_map"), []byte(transpiled.SourceMap))
+	}
+
+	return nil
+}
+
+// tapNamed returns the ID of another tap of the namespace with the name, an empty string if there is none.
+func tapNamed(tx bolted.SugaredReadTx, ns, name, exceptID string) (string, error) {
+	for it := tx.Iterator(tapsPathOf(ns)); !it.IsDone(); it.Next() {
+		id := it.GetKey()
+		if id == exceptID {
+			continue
+		}
+		opts, err := readTapOptions(tx, tapRef{namespace: ns, id: id})
+		if err != nil {
+			return "", err
+		}
+		if opts.Name == name {
+			return id, nil
+		}
+	}
+	return "", nil
+}
+
+// checkUniqueName fails with ErrConflict when another tap of the namespace has the name.
+func checkUniqueName(tx bolted.SugaredReadTx, ns, name, exceptID string) error {
+	other, err := tapNamed(tx, ns, name, exceptID)
+	if err != nil {
+		return err
+	}
+	if other != "" {
+		return fmt.Errorf("%w: tap %s is already named %q", ErrConflict, other, name)
+	}
+	return nil
+}
+
+func validTapID(id string) bool {
+	return tapIDRegexp.MatchString(id)
+}
+
+// create installs a new tap. Repeating a create with the same client-supplied
+// ID or idempotency key returns the existing tap instead of creating another one.
 func (s *Server) create(w http.ResponseWriter, r *http.Request) {
-	log := s.log.WithValues("method", r.Method, "path", r.URL.Path)
+	ns := namespaceOf(r)
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path, "namespace", ns)
 
-	cto := createTapOptions{}
+	req := data.CreateTapRequest{}
 
-	err := json.NewDecoder(r.Body).Decode(&cto)
+	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		http.Error(w, fmt.Errorf("could not decode options: %w", err).Error(), http.StatusBadRequest)
 		log.Error(err, "clould not decode tap options")
 		return
 	}
 
-	id, err := uuid.NewV6()
+	if req.ID != "" && !validTapID(req.ID) {
+		http.Error(w, fmt.Sprintf("invalid tap id %q", req.ID), http.StatusBadRequest)
+		return
+	}
+
+	idempotencyKey := r.Header.Get(data.IdempotencyKeyHeader)
+	if len(idempotencyKey) > 255 {
+		http.Error(w, "idempotency key is longer than 255 characters", http.StatusBadRequest)
+		return
+	}
+
+	requestHash, err := hashRequest(req)
 	if err != nil {
-		http.Error(w, fmt.Errorf("could not create tap id: %w", err).Error(), http.StatusBadRequest)
-		log.Error(err, "clould not create tap id")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		log.Error(err, "could not hash request")
 		return
 	}
 
-	tcd, err := json.Marshal(cto)
+	transpiled, err := prepareTap(req.TapOptions)
 	if err != nil {
-		http.Error(w, fmt.Errorf("could not marshal tap config: %w", err).Error(), http.StatusBadRequest)
-		log.Error(err, "clould not marshal tap config")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		log.Error(err, "clould not prepare tap")
 		return
 	}
 
-	tapPath := tapsPath.Append(id.String())
+	id := req.ID
+	if id == "" {
+		uid, err := uuid.NewV6()
+		if err != nil {
+			http.Error(w, fmt.Errorf("could not create tap id: %w", err).Error(), http.StatusBadRequest)
+			log.Error(err, "clould not create tap id")
+			return
+		}
+		id = uid.String()
+	}
+
+	created := true
+	etag := ""
 
 	err = bolted.SugaredWrite(s.db, func(tx bolted.SugaredWriteTx) error {
-		tx.CreateMap(tapPath)
-		tx.Put(tapPath.Append("options"), tcd)
+		ensureNamespace(tx, ns)
+		now := time.Now()
+
+		if idempotencyKey != "" {
+			existing, err := createdWithKey(tx, ns, idempotencyKey, requestHash, now)
+			if err != nil {
+				return err
+			}
+			if existing != "" {
+				id, created = existing, false
+				etag = etagOf(readRevision(tx, tapRef{namespace: ns, id: id}.path()))
+				return nil
+			}
+		}
+
+		ref := tapRef{namespace: ns, id: id}
+
+		if tx.Exists(ref.path()) {
+			old, err := readTapOptions(tx, ref)
+			if err != nil {
+				return err
+			}
+			if !sameOptions(old, req.TapOptions) {
+				return fmt.Errorf("%w: tap %s exists with different options", ErrConflict, id)
+			}
+			created = false
+		} else {
+			q, err := readQuota(tx, ns)
+			if err != nil {
+				return err
+			}
+			if q.MaxTaps > 0 && tx.Size(tapsPathOf(ns)) >= uint64(q.MaxTaps) {
+				return fmt.Errorf("%w: namespace %s is limited to %d taps", ErrQuotaExceeded, ns, q.MaxTaps)
+			}
+			err = checkUniqueName(tx, ns, req.Name, "")
+			if err != nil {
+				return err
+			}
+			tx.CreateMap(ref.path())
+			err = putTap(tx, ref.path(), req.TapOptions, transpiled)
+			if err != nil {
+				return err
+			}
+			err = appendAudit(tx, r, data.AuditCreate, ref, nil, req.TapOptions)
+			if err != nil {
+				return err
+			}
+		}
+
+		etag = etagOf(readRevision(tx, ref.path()))
+
+		if idempotencyKey != "" {
+			return putIdempotencyKey(tx, ns, idempotencyKey, requestHash, id, now)
+		}
+
 		return nil
 	})
 
-	if err != nil {
+	switch {
+	case errors.Is(err, ErrQuotaExceeded):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		log.Error(err, "quota exceeded")
+		return
+	case errors.Is(err, ErrConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+		log.Error(err, "conflicting tap")
+		return
+	case errors.Is(err, ErrIdempotencyKeyReused):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		log.Error(err, "idempotency key reused")
+		return
+	case err != nil:
 		http.Error(w, fmt.Errorf("could not store tap config: %w", err).Error(), http.StatusInternalServerError)
 		log.Error(err, "clould not store tap config")
 		return
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	w.Header().Set("content-type", "application/json")
 
-	defer func() {
-		if err != nil {
-			cancel()
-		}
-	}()
+	if !created {
+		w.Header().Set("etag", etag)
+		json.NewEncoder(w).Encode(createTapResponse{ID: id})
+		return
+	}
 
-	err = tap.Start(ctx, log, s.db, tapPath, s.bufferClient)
+	err = s.startTap(log, tapRef{namespace: ns, id: id})
 	if err != nil {
 		http.Error(w, fmt.Errorf("could start tap: %w", err).Error(), http.StatusInternalServerError)
 		log.Error(err, "clould start tap")
 		return
 	}
 
-	s.mu.Lock()
-	s.tapCancels[id.String()] = cancel
-	s.mu.Unlock()
-
+	w.Header().Set("etag", etag)
 	w.WriteHeader(http.StatusCreated)
 
-	json.NewEncoder(w).Encode(createTapResponse{ID: id.String()})
+	json.NewEncoder(w).Encode(createTapResponse{ID: id})
+}
+
+// sameOptions compares options by their stored form.
+func sameOptions(a, b data.TapOptions) bool {
+	ad, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bd, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(ad, bd)
 }
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/draganm/bolted"
+	"github.com/draganm/event-tap/data"
 	"github.com/gorilla/mux"
 )
 
@@ -13,16 +14,20 @@ var ErrNotFound = errors.New("not found")
 
 func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
 
-	tapID := mux.Vars(r)["tapID"]
-	log := s.log.WithValues("method", r.Method, "path", r.URL.Path, "tapID", tapID)
+	ref := tapRef{namespace: namespaceOf(r), id: mux.Vars(r)["tapID"]}
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path, "namespace", ref.namespace, "tapID", ref.id)
 
 	err := bolted.SugaredWrite(s.db, func(tx bolted.SugaredWriteTx) error {
-		webhookPath := tapsPath.Append(tapID)
-		if !tx.Exists(webhookPath) {
-			return ErrNotFound
+		old, err := readTapOptions(tx, ref)
+		if err != nil {
+			return err
 		}
-		tx.Delete(webhookPath)
-		return nil
+		err = checkIfMatch(tx, r, ref)
+		if err != nil {
+			return err
+		}
+		tx.Delete(ref.path())
+		return appendAudit(tx, r, data.AuditDelete, ref, old, nil)
 	})
 
 	if errors.Is(err, ErrNotFound) {
@@ -31,18 +36,19 @@ func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if errors.Is(err, ErrPreconditionFailed) {
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		log.Error(err, "revision mismatch")
+		return
+	}
+
 	if err != nil {
 		http.Error(w, fmt.Errorf("could not delete tap: %w", err).Error(), http.StatusInternalServerError)
 		log.Error(err, "could not delete tap")
 		return
 	}
 
-	s.mu.Lock()
-	cancel, found := s.tapCancels[tapID]
-	if found {
-		cancel()
-	}
-	s.mu.Unlock()
+	s.forgetTap(ref)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/draganm/event-tap/data"
+	"github.com/draganm/event-tap/server/tap"
+)
+
+// maxDryRunEvents limits the number of events a dry run can read from the buffer.
+const maxDryRunEvents = 1000
+
+// dryRunPollTimeout is how long a dry run waits for events when the buffer has none after the requested ID.
+const dryRunPollTimeout = 500 * time.Millisecond
+
+func (s *Server) dryRun(w http.ResponseWriter, r *http.Request) {
+
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path)
+
+	req := data.TapTestRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not decode test request: %w", err).Error(), http.StatusBadRequest)
+		log.Error(err, "could not decode test request")
+		return
+	}
+
+	err = tap.ValidateTransform(req.Options)
+	if err != nil {
+		http.Error(w, fmt.Errorf("invalid transform: %w", err).Error(), http.StatusBadRequest)
+		log.Error(err, "invalid transform")
+		return
+	}
+
+	var events [][]any
+
+	switch {
+	case req.FromBuffer != nil && len(req.Events) > 0:
+		http.Error(w, "events and from_buffer are mutually exclusive", http.StatusBadRequest)
+		return
+	case req.FromBuffer != nil:
+		if req.FromBuffer.Limit <= 0 || req.FromBuffer.Limit > maxDryRunEvents {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxDryRunEvents), http.StatusBadRequest)
+			return
+		}
+		events, err = s.readBufferEvents(r.Context(), req.FromBuffer.After, req.FromBuffer.Limit)
+		if err != nil {
+			http.Error(w, fmt.Errorf("could not read events from buffer: %w", err).Error(), http.StatusBadGateway)
+			log.Error(err, "could not read events from buffer")
+			return
+		}
+	default:
+		events = tap.SampleEventsWithIDs(req.Events)
+	}
+
+	// the tested code is not trusted to ever return
+	ctx, cancel := context.WithTimeout(r.Context(), s.dryRunTimeout)
+	defer cancel()
+
+	res, err := tap.DryRun(ctx, log, req.Options, events, tap.Services{
+		Libraries:   &libraries{db: s.db},
+		LookupHosts: s.lookupHosts,
+	})
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not run test: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not run test")
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// readBufferEvents reads up to limit events following the event with the given ID.
+// Reading stops without an error when the buffer has no more events.
+func (s *Server) readBufferEvents(ctx context.Context, after string, limit int) ([][]any, error) {
+	result := [][]any{}
+	for len(result) < limit {
+		pollCtx, cancel := context.WithTimeout(ctx, dryRunPollTimeout)
+		events := []any{}
+		ids, err := s.bufferClient.PollForEvents(pollCtx, after, limit-len(result), &events)
+		timedOut := err != nil && ctx.Err() == nil && pollCtx.Err() != nil
+		cancel()
+
+		switch {
+		case timedOut:
+			return result, nil
+		case err != nil:
+			return nil, err
+		}
+
+		for i, ev := range events {
+			result = append(result, []any{ids[i], ev})
+		}
+
+		if len(ids) == 0 {
+			return result, nil
+		}
+
+		after = ids[len(ids)-1]
+	}
+	return result, nil
+}
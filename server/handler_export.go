@@ -0,0 +1,331 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/draganm/event-tap/data"
+	"github.com/draganm/event-tap/server/tap"
+	"github.com/go-logr/logr"
+)
+
+// readFlatMap returns the values of a map without nested maps, nil when it does not exist.
+func readFlatMap(tx bolted.SugaredReadTx, path dbpath.Path) map[string]string {
+	if !tx.Exists(path) || tx.Size(path) == 0 {
+		return nil
+	}
+	values := map[string]string{}
+	for it := tx.Iterator(path); !it.IsDone(); it.Next() {
+		values[it.GetKey()] = string(it.GetValue())
+	}
+	return values
+}
+
+func writeFlatMap(tx bolted.SugaredWriteTx, path dbpath.Path, values map[string]string) {
+	tx.CreateMap(path)
+	for k, v := range values {
+		tx.Put(path.Append(k), []byte(v))
+	}
+}
+
+// export writes the taps of all namespaces the token may access. Cursors
+// and state are read in one transaction, so they are consistent.
+func (s *Server) export(w http.ResponseWriter, r *http.Request) {
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path)
+
+	only := r.URL.Query().Get("namespace")
+	p := principalFromContext(r.Context())
+
+	archive := data.Archive{
+		Version:    data.ArchiveVersion,
+		ExportedAt: time.Now().UTC(),
+		Taps:       []data.ArchivedTap{},
+	}
+
+	err := bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) error {
+		codes := []string{}
+		for nsIt := tx.Iterator(namespacesPath); !nsIt.IsDone(); nsIt.Next() {
+			ns := nsIt.GetKey()
+			if !p.allows(ns) || (only != "" && ns != only) {
+				continue
+			}
+			for it := tx.Iterator(tapsPathOf(ns)); !it.IsDone(); it.Next() {
+				ref := tapRef{namespace: ns, id: it.GetKey()}
+				opts, err := readTapOptions(tx, ref)
+				if err != nil {
+					return err
+				}
+				at := data.ArchivedTap{
+					Namespace: ns,
+					ID:        ref.id,
+					Options:   opts,
+					Paused:    isPaused(tx, ref),
+					State:     readFlatMap(tx, ref.path().Append("state")),
+					Windows:   readFlatMap(tx, ref.path().Append("windows")),
+				}
+				if tx.Exists(ref.path().Append("last_id")) {
+					at.LastID = string(tx.Get(ref.path().Append("last_id")))
+				}
+				archive.Taps = append(archive.Taps, at)
+
+				// transpiled code requires modules imported by the original
+				if tx.Exists(ref.path().Append("transpiled")) {
+					codes = append(codes, string(tx.Get(ref.path().Append("transpiled"))))
+				} else {
+					codes = append(codes, opts.Code)
+				}
+			}
+		}
+		// without the libraries they require the taps could not start on another server
+		archive.Libraries = requiredLibraries(tx, codes)
+		return nil
+	})
+
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not export taps: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not export taps")
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(archive)
+}
+
+func (s *Server) importArchive(w http.ResponseWriter, r *http.Request) {
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path)
+
+	onConflict := r.URL.Query().Get("on_conflict")
+	switch onConflict {
+	case "":
+		onConflict = data.OnConflictFail
+	case data.OnConflictFail, data.OnConflictSkip, data.OnConflictOverwrite:
+	default:
+		http.Error(w, fmt.Sprintf("unknown on_conflict %q, use fail, skip or overwrite", onConflict), http.StatusBadRequest)
+		return
+	}
+
+	archive := data.Archive{}
+	err := json.NewDecoder(r.Body).Decode(&archive)
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not decode archive: %w", err).Error(), http.StatusBadRequest)
+		log.Error(err, "could not decode archive")
+		return
+	}
+
+	if archive.Version < 1 || archive.Version > data.ArchiveVersion {
+		http.Error(w, fmt.Sprintf("unsupported archive version %d", archive.Version), http.StatusBadRequest)
+		return
+	}
+
+	p := principalFromContext(r.Context())
+	transpiled := make([]*tap.Transpiled, len(archive.Taps))
+	seen := map[tapRef]bool{}
+	for i, at := range archive.Taps {
+		if !validNamespace(at.Namespace) || !validTapID(at.ID) {
+			http.Error(w, fmt.Sprintf("invalid namespace or id of tap %q", at.Options.Name), http.StatusBadRequest)
+			return
+		}
+		ref := tapRef{namespace: at.Namespace, id: at.ID}
+		if seen[ref] {
+			http.Error(w, fmt.Sprintf("tap %s/%s is in the archive more than once", at.Namespace, at.ID), http.StatusBadRequest)
+			return
+		}
+		seen[ref] = true
+		if !p.allows(at.Namespace) {
+			http.Error(w, fmt.Sprintf("token is not bound to namespace %s", at.Namespace), http.StatusForbidden)
+			return
+		}
+		transpiled[i], err = prepareTap(at.Options)
+		if err != nil {
+			err = fmt.Errorf("tap %s/%s: %w", at.Namespace, at.ID, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			log.Error(err, "could not prepare tap")
+			return
+		}
+	}
+
+	for _, al := range archive.Libraries {
+		err = validateArchivedLibrary(al)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			log.Error(err, "invalid library")
+			return
+		}
+	}
+
+	res := data.ImportResult{
+		Created:     []string{},
+		Overwritten: []string{},
+		Skipped:     []string{},
+	}
+	toStart := []tapRef{}
+
+	// running taps that are overwritten must not store their cursor afterwards
+	if onConflict == data.OnConflictOverwrite {
+		for _, at := range archive.Taps {
+			s.stopTap(tapRef{namespace: at.Namespace, id: at.ID})
+		}
+	}
+
+	err = bolted.SugaredWrite(s.db, func(tx bolted.SugaredWriteTx) error {
+		err := installLibraries(tx, archive.Libraries, p == nil || p.Scope == data.ScopeAdmin)
+		if err != nil {
+			return err
+		}
+
+		conflicts := []string{}
+		for _, at := range archive.Taps {
+			ref := tapRef{namespace: at.Namespace, id: at.ID}
+			if tx.Exists(ref.path()) {
+				conflicts = append(conflicts, at.Namespace+"/"+at.ID)
+			}
+		}
+
+		if len(conflicts) > 0 && onConflict == data.OnConflictFail {
+			return fmt.Errorf("%w: taps %s exist", ErrConflict, strings.Join(conflicts, ", "))
+		}
+
+		added := map[string]int{}
+		for i, at := range archive.Taps {
+			ref := tapRef{namespace: at.Namespace, id: at.ID}
+			name := at.Namespace + "/" + at.ID
+			ensureNamespace(tx, at.Namespace)
+
+			var old any
+			if tx.Exists(ref.path()) {
+				if onConflict == data.OnConflictSkip {
+					res.Skipped = append(res.Skipped, name)
+					continue
+				}
+				old, err = readTapOptions(tx, ref)
+				if err != nil {
+					return err
+				}
+				clearTapRuntime(tx, ref)
+				res.Overwritten = append(res.Overwritten, name)
+			} else {
+				added[at.Namespace]++
+				res.Created = append(res.Created, name)
+				tx.CreateMap(ref.path())
+			}
+
+			err := checkUniqueName(tx, at.Namespace, at.Options.Name, at.ID)
+			if err != nil {
+				return err
+			}
+			err = putTap(tx, ref.path(), at.Options, transpiled[i])
+			if err != nil {
+				return err
+			}
+			if at.LastID != "" {
+				tx.Put(ref.path().Append("last_id"), []byte(at.LastID))
+			}
+			writeFlatMap(tx, ref.path().Append("state"), at.State)
+			writeFlatMap(tx, ref.path().Append("windows"), at.Windows)
+			if at.Paused {
+				tx.Put(ref.path().Append("paused"), []byte{})
+				tx.Put(ref.path().Append("status"), []byte(StatusPaused))
+			} else {
+				toStart = append(toStart, ref)
+			}
+
+			err = appendAudit(tx, r, data.AuditImport, ref, old, at.Options)
+			if err != nil {
+				return err
+			}
+		}
+
+		for ns, n := range added {
+			q, err := readQuota(tx, ns)
+			if err != nil {
+				return err
+			}
+			if q.MaxTaps > 0 && tx.Size(tapsPathOf(ns)) > uint64(q.MaxTaps) {
+				return fmt.Errorf("%w: namespace %s is limited to %d taps, importing %d would exceed it", ErrQuotaExceeded, ns, q.MaxTaps, n)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil && onConflict == data.OnConflictOverwrite {
+		// nothing was imported, the stopped taps continue with their stored options
+		s.startStoredTaps(log, archive)
+	}
+
+	switch {
+	case errors.Is(err, ErrConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+		log.Error(err, "conflicting taps")
+		return
+	case errors.Is(err, ErrQuotaExceeded):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		log.Error(err, "quota exceeded")
+		return
+	case errors.Is(err, ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		log.Error(err, "could not install libraries")
+		return
+	case err != nil:
+		http.Error(w, fmt.Errorf("could not import taps: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not import taps")
+		return
+	}
+
+	for _, ref := range toStart {
+		err = s.startTap(log, ref)
+		if err != nil {
+			log.Error(err, "could not start tap", "namespace", ref.namespace, "tapID", ref.id)
+		}
+	}
+
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// clearTapRuntime removes everything but the options and the revisions of a
+// tap, so an overwritten tap keeps its history.
+func clearTapRuntime(tx bolted.SugaredWriteTx, ref tapRef) {
+	children := []string{}
+	for it := tx.Iterator(ref.path()); !it.IsDone(); it.Next() {
+		switch it.GetKey() {
+		case "options", "revision", "revisions":
+		default:
+			children = append(children, it.GetKey())
+		}
+	}
+	for _, c := range children {
+		tx.Delete(ref.path().Append(c))
+	}
+}
+
+// startStoredTaps starts the installed taps with the IDs of archived ones, unless they are paused.
+func (s *Server) startStoredTaps(log logr.Logger, archive data.Archive) {
+	refs := []tapRef{}
+	err := bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) error {
+		for _, at := range archive.Taps {
+			ref := tapRef{namespace: at.Namespace, id: at.ID}
+			if tx.Exists(ref.path()) && !isPaused(tx, ref) {
+				refs = append(refs, ref)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error(err, "could not find taps to restart")
+		return
+	}
+
+	for _, ref := range refs {
+		err = s.startTap(log, ref)
+		if err != nil {
+			log.Error(err, "could not start tap", "namespace", ref.namespace, "tapID", ref.id)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/event-tap/data"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+
+	ref := tapRef{namespace: namespaceOf(r), id: mux.Vars(r)["tapID"]}
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path, "namespace", ref.namespace, "tapID", ref.id)
+
+	var opts data.TapOptions
+	var rev uint64
+	err := bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) (err error) {
+		opts, err = readTapOptions(tx, ref)
+		rev = readRevision(tx, ref.path())
+		return err
+	})
+
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+		log.Error(err, "tap not found")
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not read tap: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not read tap")
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.Header().Set("etag", etagOf(rev))
+	json.NewEncoder(w).Encode(opts)
+}
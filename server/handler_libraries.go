@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dop251/goja"
+	"github.com/draganm/bolted"
+	"github.com/draganm/event-tap/data"
+	"github.com/draganm/event-tap/server/tap"
+)
+
+var ErrConflict = errors.New("conflict")
+
+func (s *Server) publishLibrary(w http.ResponseWriter, r *http.Request) {
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path)
+
+	lib := data.Library{}
+
+	err := json.NewDecoder(r.Body).Decode(&lib)
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not decode library: %w", err).Error(), http.StatusBadRequest)
+		log.Error(err, "could not decode library")
+		return
+	}
+
+	if lib.Name == "" || lib.Version == "" || lib.Version == tap.LatestVersion {
+		http.Error(w, "library needs a name and a version other than latest", http.StatusBadRequest)
+		return
+	}
+
+	if name, _ := tap.ParseModuleID(lib.Name); name != lib.Name {
+		http.Error(w, "library name must not contain a version", http.StatusBadRequest)
+		return
+	}
+
+	_, err = goja.Compile(lib.Name+".js", lib.Code, true)
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not parse library code: %w", err).Error(), http.StatusBadRequest)
+		log.Error(err, "could not parse library code")
+		return
+	}
+
+	err = bolted.SugaredWrite(s.db, func(tx bolted.SugaredWriteTx) error {
+		libPath := librariesPath.Append(lib.Name)
+		if !tx.Exists(libPath) {
+			tx.CreateMap(libPath)
+			tx.CreateMap(libPath.Append("versions"))
+		}
+
+		versionPath := libPath.Append("versions", lib.Version)
+		if tx.Exists(versionPath) {
+			return ErrConflict
+		}
+
+		tx.Put(versionPath, []byte(lib.Code))
+		tx.Put(libPath.Append("latest"), []byte(lib.Version))
+		return nil
+	})
+
+	if errors.Is(err, ErrConflict) {
+		http.Error(w, "library version already exists", http.StatusConflict)
+		log.Error(err, "library version already exists")
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not store library: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not store library")
+		return
+	}
+
+	// taps requiring the latest version pick up the new one on restart
+	s.mu.Lock()
+	dependent := []tapRef{}
+	for ref, rt := range s.taps {
+		if rt.tap.TracksLatest(lib.Name) {
+			dependent = append(dependent, ref)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, ref := range dependent {
+		s.restartUnlessPaused(log.WithValues("namespace", ref.namespace, "tapID", ref.id), ref)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) listLibraries(w http.ResponseWriter, r *http.Request) {
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path)
+
+	entries := []data.LibraryListEntry{}
+
+	err := bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) error {
+		for it := tx.Iterator(librariesPath); !it.IsDone(); it.Next() {
+			libPath := librariesPath.Append(it.GetKey())
+			e := data.LibraryListEntry{
+				Name:     it.GetKey(),
+				Latest:   string(tx.Get(libPath.Append("latest"))),
+				Versions: []string{},
+			}
+			for vit := tx.Iterator(libPath.Append("versions")); !vit.IsDone(); vit.Next() {
+				e.Versions = append(e.Versions, vit.GetKey())
+			}
+			entries = append(entries, e)
+		}
+		return nil
+	})
+
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not list libraries: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not list libraries")
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/draganm/bolted"
 	"github.com/draganm/event-tap/data"
@@ -14,14 +15,36 @@ func (s *Server) list(w http.ResponseWriter, r *http.Request) {
 	limit := 100
 
 	cursor := r.URL.Query().Get("cursor")
+	namePrefix := r.URL.Query().Get("name_prefix")
 
-	log := s.log.WithValues("method", r.Method, "path", r.URL.Path)
+	ns := namespaceOf(r)
+	tapsPath := tapsPathOf(ns)
+
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path, "namespace", ns)
+
+	selector, err := parseSelector(r.URL.Query().Get("selector"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	statusFilter := r.URL.Query().Get("status")
+	switch statusFilter {
+	case "", data.StatusFilterRunning, data.StatusFilterPaused, data.StatusFilterFailing:
+	default:
+		http.Error(w, fmt.Sprintf("unknown status %q, use running, paused or failing", statusFilter), http.StatusBadRequest)
+		return
+	}
 
 	page := &data.TapListPage{
 		Entries: []data.TapListEntry{},
 	}
 
-	err := bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) error {
+	err = bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) error {
+
+		if !tx.Exists(tapsPath) {
+			return nil
+		}
 
 		it := tx.Iterator(tapsPath)
 		if cursor != "" {
@@ -38,10 +61,27 @@ func (s *Server) list(w http.ResponseWriter, r *http.Request) {
 				return fmt.Errorf("could not parse %s: %w", optsPath.String(), err)
 			}
 
+			status := ""
+			statusPath := tapsPath.Append(it.GetKey(), "status")
+			if tx.Exists(statusPath) {
+				status = string(tx.Get(statusPath))
+			}
+
+			if !strings.HasPrefix(opts.Name, namePrefix) || !selector.matches(opts.Labels) {
+				continue
+			}
+
+			if statusFilter != "" && statusFilterOf(status) != statusFilter {
+				continue
+			}
+
 			page.Entries = append(page.Entries, data.TapListEntry{
 				Name:       opts.Name,
 				ID:         it.GetKey(),
 				WebhookURL: opts.WebhookURL,
+				Status:     status,
+				Revision:   readRevision(tx, tapsPath.Append(it.GetKey())),
+				Labels:     opts.Labels,
 			})
 
 			page.Cursor = it.GetKey()
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/event-tap/data"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) logs(w http.ResponseWriter, r *http.Request) {
+
+	ref := tapRef{namespace: namespaceOf(r), id: mux.Vars(r)["tapID"]}
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path, "namespace", ref.namespace, "tapID", ref.id)
+
+	after := uint64(0)
+	if v := r.URL.Query().Get("after"); v != "" {
+		var err error
+		after, err = strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, fmt.Errorf("could not parse after: %w", err).Error(), http.StatusBadRequest)
+			log.Error(err, "could not parse after")
+			return
+		}
+	}
+
+	s.mu.Lock()
+	lb, found := s.logBuffers[ref]
+	s.mu.Unlock()
+
+	entries := []data.LogEntry{}
+
+	if found {
+		entries = lb.Since(after)
+	} else {
+		// taps that did not start since the server started have not logged anything
+		err := bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) error {
+			if !tx.Exists(ref.path()) {
+				return ErrNotFound
+			}
+			return nil
+		})
+
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, "not found", http.StatusNotFound)
+			log.Error(err, "tap not found")
+			return
+		}
+
+		if err != nil {
+			http.Error(w, fmt.Errorf("could not read tap: %w", err).Error(), http.StatusInternalServerError)
+			log.Error(err, "could not read tap")
+			return
+		}
+	}
+
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(data.TapLogs{Entries: entries})
+}
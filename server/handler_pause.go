@@ -0,0 +1,123 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/event-tap/data"
+	"github.com/gorilla/mux"
+)
+
+// StatusPaused is the status of a tap that is not processing events until it is resumed.
+const StatusPaused = "paused"
+
+func (s *Server) pause(w http.ResponseWriter, r *http.Request) {
+
+	ref := tapRef{namespace: namespaceOf(r), id: mux.Vars(r)["tapID"]}
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path, "namespace", ref.namespace, "tapID", ref.id)
+
+	if !s.preconditionHolds(w, r, ref) {
+		return
+	}
+
+	// the tap is stopped first, so it does not overwrite the status afterwards,
+	// and can't be started again before it is marked as paused
+	lc := s.lifecycleOf(ref)
+	lc.Lock()
+	s.stopRunning(ref)
+
+	err := bolted.SugaredWrite(s.db, func(tx bolted.SugaredWriteTx) error {
+		if !tx.Exists(ref.path()) {
+			return ErrNotFound
+		}
+		err := checkIfMatch(tx, r, ref)
+		if err != nil {
+			return err
+		}
+		if isPaused(tx, ref) {
+			return nil
+		}
+		tx.Put(ref.path().Append("paused"), []byte{})
+		tx.Put(ref.path().Append("status"), []byte(StatusPaused))
+		return appendAudit(tx, r, data.AuditPause, ref, nil, nil)
+	})
+	lc.Unlock()
+
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+		log.Error(err, "tap not found")
+		return
+	}
+
+	if errors.Is(err, ErrPreconditionFailed) {
+		// the tap was changed since the check, it continues as it was
+		s.restartUnlessPaused(log, ref)
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		log.Error(err, "revision mismatch")
+		return
+	}
+
+	if err != nil {
+		// nothing was changed, the tap continues as it was
+		s.restartUnlessPaused(log, ref)
+		http.Error(w, fmt.Errorf("could not pause tap: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not pause tap")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) resume(w http.ResponseWriter, r *http.Request) {
+
+	ref := tapRef{namespace: namespaceOf(r), id: mux.Vars(r)["tapID"]}
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path, "namespace", ref.namespace, "tapID", ref.id)
+
+	resumed := false
+	err := bolted.SugaredWrite(s.db, func(tx bolted.SugaredWriteTx) error {
+		if !tx.Exists(ref.path()) {
+			return ErrNotFound
+		}
+		err := checkIfMatch(tx, r, ref)
+		if err != nil {
+			return err
+		}
+		if !isPaused(tx, ref) {
+			return nil
+		}
+		tx.Delete(ref.path().Append("paused"))
+		resumed = true
+		return appendAudit(tx, r, data.AuditResume, ref, nil, nil)
+	})
+
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+		log.Error(err, "tap not found")
+		return
+	}
+
+	if errors.Is(err, ErrPreconditionFailed) {
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		log.Error(err, "revision mismatch")
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not resume tap: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not resume tap")
+		return
+	}
+
+	if resumed {
+		err = s.startTap(log, ref)
+		if err != nil {
+			http.Error(w, fmt.Errorf("could not start tap: %w", err).Error(), http.StatusInternalServerError)
+			log.Error(err, "could not start tap")
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
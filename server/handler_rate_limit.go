@@ -0,0 +1,94 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/event-tap/data"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) setRateLimit(w http.ResponseWriter, r *http.Request) {
+
+	ref := tapRef{namespace: namespaceOf(r), id: mux.Vars(r)["tapID"]}
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path, "namespace", ref.namespace, "tapID", ref.id)
+
+	rl := &data.RateLimit{}
+	err := json.NewDecoder(r.Body).Decode(rl)
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not decode rate limit: %w", err).Error(), http.StatusBadRequest)
+		log.Error(err, "could not decode rate limit")
+		return
+	}
+
+	if rl.RequestsPerSecond < 0 || rl.EventsPerSecond < 0 {
+		http.Error(w, "rate limits must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	var rev uint64
+	err = bolted.SugaredWrite(s.db, func(tx bolted.SugaredWriteTx) error {
+		optsPath := ref.path().Append("options")
+		if !tx.Exists(optsPath) {
+			return ErrNotFound
+		}
+
+		err := checkIfMatch(tx, r, ref)
+		if err != nil {
+			return err
+		}
+
+		opts := &data.TapOptions{}
+		err = json.Unmarshal(tx.Get(optsPath), opts)
+		if err != nil {
+			return fmt.Errorf("could not parse %s: %w", optsPath.String(), err)
+		}
+
+		old := *opts
+		opts.RateLimit = rl
+
+		d, err := json.Marshal(opts)
+		if err != nil {
+			return fmt.Errorf("could not marshal tap options: %w", err)
+		}
+
+		err = nextRevision(tx, ref.path(), *opts)
+		if err != nil {
+			return err
+		}
+		rev = readRevision(tx, ref.path())
+		tx.Put(optsPath, d)
+		return appendAudit(tx, r, data.AuditUpdate, ref, old, opts)
+	})
+
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+		log.Error(err, "tap not found")
+		return
+	}
+
+	if errors.Is(err, ErrPreconditionFailed) {
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		log.Error(err, "revision mismatch")
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not update rate limit: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not update rate limit")
+		return
+	}
+
+	s.mu.Lock()
+	rt, found := s.taps[ref]
+	if found {
+		rt.tap.SetRateLimit(rl)
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("etag", etagOf(rev))
+	w.WriteHeader(http.StatusNoContent)
+}
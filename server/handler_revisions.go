@@ -0,0 +1,143 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/event-tap/data"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) revisions(w http.ResponseWriter, r *http.Request) {
+
+	ref := tapRef{namespace: namespaceOf(r), id: mux.Vars(r)["tapID"]}
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path, "namespace", ref.namespace, "tapID", ref.id)
+
+	var revisions []data.TapRevision
+	err := bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) (err error) {
+		revisions, err = readRevisions(tx, ref)
+		return err
+	})
+
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+		log.Error(err, "tap not found")
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not read revisions: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not read revisions")
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// rollback stores the options of an earlier revision as the next revision of
+// the tap and restarts it. The cursor and state of the tap are kept.
+func (s *Server) rollback(w http.ResponseWriter, r *http.Request) {
+
+	ref := tapRef{namespace: namespaceOf(r), id: mux.Vars(r)["tapID"]}
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path, "namespace", ref.namespace, "tapID", ref.id)
+
+	target, err := strconv.ParseUint(r.URL.Query().Get("revision"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Errorf("invalid revision: %w", err).Error(), http.StatusBadRequest)
+		return
+	}
+
+	var opts data.TapOptions
+	err = bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) error {
+		revisions, err := readRevisions(tx, ref)
+		if err != nil {
+			return err
+		}
+		for _, tr := range revisions {
+			if tr.Revision == target {
+				opts = tr.Options
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: revision %d", ErrNotFound, target)
+	})
+
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		log.Error(err, "revision not found")
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not read revisions: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not read revisions")
+		return
+	}
+
+	transpiled, err := prepareTap(opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		log.Error(err, "could not prepare tap")
+		return
+	}
+
+	paused := false
+	var rev uint64
+	err = bolted.SugaredWrite(s.db, func(tx bolted.SugaredWriteTx) error {
+		old, err := readTapOptions(tx, ref)
+		if err != nil {
+			return err
+		}
+		err = checkIfMatch(tx, r, ref)
+		if err != nil {
+			return err
+		}
+		paused = isPaused(tx, ref)
+		err = checkUniqueName(tx, ref.namespace, opts.Name, ref.id)
+		if err != nil {
+			return err
+		}
+		err = putTap(tx, ref.path(), opts, transpiled)
+		if err != nil {
+			return err
+		}
+		rev = readRevision(tx, ref.path())
+		return appendAudit(tx, r, data.AuditRollback, ref, old, opts)
+	})
+
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+		log.Error(err, "tap not found")
+		return
+	case errors.Is(err, ErrPreconditionFailed):
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		log.Error(err, "revision mismatch")
+		return
+	case errors.Is(err, ErrConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+		log.Error(err, "conflicting tap name")
+		return
+	case err != nil:
+		http.Error(w, fmt.Errorf("could not roll back tap: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not roll back tap")
+		return
+	}
+
+	if !paused {
+		err = s.restartTap(log, ref)
+		if err != nil {
+			http.Error(w, fmt.Errorf("could not restart tap: %w", err).Error(), http.StatusInternalServerError)
+			log.Error(err, "could not restart tap")
+			return
+		}
+	}
+
+	w.Header().Set("etag", etagOf(rev))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/event-tap/data"
+	"github.com/gorilla/mux"
+)
+
+// seek moves the cursor of a tap, the next event it processes is the one following the new cursor.
+func (s *Server) seek(w http.ResponseWriter, r *http.Request) {
+
+	ref := tapRef{namespace: namespaceOf(r), id: mux.Vars(r)["tapID"]}
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path, "namespace", ref.namespace, "tapID", ref.id)
+
+	cursor := data.Cursor{}
+	err := json.NewDecoder(r.Body).Decode(&cursor)
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not decode cursor: %w", err).Error(), http.StatusBadRequest)
+		log.Error(err, "could not decode cursor")
+		return
+	}
+
+	if !s.preconditionHolds(w, r, ref) {
+		return
+	}
+
+	// the tap is stopped first, so it can't store its old cursor afterwards,
+	// and can't be started again before the new cursor is stored
+	lc := s.lifecycleOf(ref)
+	lc.Lock()
+	s.stopRunning(ref)
+
+	paused := false
+	err = bolted.SugaredWrite(s.db, func(tx bolted.SugaredWriteTx) error {
+		if !tx.Exists(ref.path()) {
+			return ErrNotFound
+		}
+		err := checkIfMatch(tx, r, ref)
+		if err != nil {
+			return err
+		}
+		paused = isPaused(tx, ref)
+
+		lastIDPath := ref.path().Append("last_id")
+		old := data.Cursor{}
+		if tx.Exists(lastIDPath) {
+			old.LastID = string(tx.Get(lastIDPath))
+		}
+
+		tx.Put(lastIDPath, []byte(cursor.LastID))
+		return appendAudit(tx, r, data.AuditSeek, ref, old, cursor)
+	})
+	lc.Unlock()
+
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+		log.Error(err, "tap not found")
+		return
+	}
+
+	if errors.Is(err, ErrPreconditionFailed) {
+		// the tap was changed since the check, it continues from its old cursor
+		s.restartUnlessPaused(log, ref)
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		log.Error(err, "revision mismatch")
+		return
+	}
+
+	if err != nil {
+		// nothing was changed, the tap continues as it was
+		s.restartUnlessPaused(log, ref)
+		http.Error(w, fmt.Errorf("could not seek tap: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not seek tap")
+		return
+	}
+
+	if !paused {
+		err = s.startTap(log, ref)
+		if err != nil {
+			http.Error(w, fmt.Errorf("could not start tap: %w", err).Error(), http.StatusInternalServerError)
+			log.Error(err, "could not start tap")
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
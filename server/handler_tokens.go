@@ -0,0 +1,150 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/event-tap/data"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) createToken(w http.ResponseWriter, r *http.Request) {
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path)
+
+	if !s.authRequired() {
+		// stored tokens would not be checked, and anyone could create an admin token
+		http.Error(w, "authentication is disabled, configure an admin token to create tokens", http.StatusConflict)
+		return
+	}
+
+	ctr := data.CreateTokenRequest{}
+	err := json.NewDecoder(r.Body).Decode(&ctr)
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not decode token request: %w", err).Error(), http.StatusBadRequest)
+		log.Error(err, "could not decode token request")
+		return
+	}
+
+	if ctr.Name == "" {
+		http.Error(w, "token needs a name", http.StatusBadRequest)
+		return
+	}
+
+	_, found := scopeRank[ctr.Scope]
+	if !found {
+		http.Error(w, fmt.Sprintf("unknown scope %q, use read, write or admin", ctr.Scope), http.StatusBadRequest)
+		return
+	}
+
+	for _, ns := range ctr.Namespaces {
+		if !validNamespace(ns) {
+			http.Error(w, fmt.Sprintf("invalid namespace %q", ns), http.StatusBadRequest)
+			return
+		}
+	}
+
+	id, token, err := newToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		log.Error(err, "could not create token")
+		return
+	}
+
+	_, secret, _ := cutToken(token)
+
+	st, err := json.Marshal(storedToken{
+		Name:       ctr.Name,
+		Scope:      ctr.Scope,
+		Namespaces: ctr.Namespaces,
+		Hash:       hashSecret(secret),
+		CreatedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not marshal token: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not marshal token")
+		return
+	}
+
+	err = bolted.SugaredWrite(s.db, func(tx bolted.SugaredWriteTx) error {
+		tx.Put(tokensPath.Append(id), st)
+		return nil
+	})
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not store token: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not store token")
+		return
+	}
+
+	log.Info("token created", "tokenID", id, "name", ctr.Name, "scope", ctr.Scope, "namespaces", ctr.Namespaces)
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(data.CreatedToken{ID: id, Token: token})
+}
+
+func (s *Server) listTokens(w http.ResponseWriter, r *http.Request) {
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path)
+
+	entries := []data.TokenListEntry{}
+
+	err := bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) error {
+		for it := tx.Iterator(tokensPath); !it.IsDone(); it.Next() {
+			st := storedToken{}
+			err := json.Unmarshal(it.GetValue(), &st)
+			if err != nil {
+				return fmt.Errorf("could not parse token %s: %w", it.GetKey(), err)
+			}
+			entries = append(entries, data.TokenListEntry{
+				ID:         it.GetKey(),
+				Name:       st.Name,
+				Scope:      st.Scope,
+				Namespaces: st.Namespaces,
+				CreatedAt:  st.CreatedAt,
+			})
+		}
+		return nil
+	})
+
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not list tokens: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not list tokens")
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+func (s *Server) revokeToken(w http.ResponseWriter, r *http.Request) {
+	tokenID := mux.Vars(r)["tokenID"]
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path, "tokenID", tokenID)
+
+	err := bolted.SugaredWrite(s.db, func(tx bolted.SugaredWriteTx) error {
+		tokenPath := tokensPath.Append(tokenID)
+		if !tx.Exists(tokenPath) {
+			return ErrNotFound
+		}
+		tx.Delete(tokenPath)
+		return nil
+	})
+
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+		log.Error(err, "token not found")
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not revoke token: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not revoke token")
+		return
+	}
+
+	log.Info("token revoked")
+
+	w.WriteHeader(http.StatusNoContent)
+}
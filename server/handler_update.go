@@ -0,0 +1,112 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/event-tap/data"
+	"github.com/gorilla/mux"
+)
+
+// readTapOptions returns the stored options of a tap or ErrNotFound.
+func readTapOptions(tx bolted.SugaredReadTx, ref tapRef) (data.TapOptions, error) {
+	opts := data.TapOptions{}
+	optsPath := ref.path().Append("options")
+	if !tx.Exists(optsPath) {
+		return opts, ErrNotFound
+	}
+	err := json.Unmarshal(tx.Get(optsPath), &opts)
+	if err != nil {
+		return opts, fmt.Errorf("could not parse %s: %w", optsPath.String(), err)
+	}
+	return opts, nil
+}
+
+func isPaused(tx bolted.SugaredReadTx, ref tapRef) bool {
+	return tx.Exists(ref.path().Append("paused"))
+}
+
+// update replaces the options of a tap. The tap keeps its position in the buffer and its state.
+func (s *Server) update(w http.ResponseWriter, r *http.Request) {
+
+	ref := tapRef{namespace: namespaceOf(r), id: mux.Vars(r)["tapID"]}
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path, "namespace", ref.namespace, "tapID", ref.id)
+
+	opts := data.TapOptions{}
+	err := json.NewDecoder(r.Body).Decode(&opts)
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not decode options: %w", err).Error(), http.StatusBadRequest)
+		log.Error(err, "could not decode tap options")
+		return
+	}
+
+	transpiled, err := prepareTap(opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		log.Error(err, "could not prepare tap")
+		return
+	}
+
+	paused := false
+	var rev uint64
+	err = bolted.SugaredWrite(s.db, func(tx bolted.SugaredWriteTx) error {
+		old, err := readTapOptions(tx, ref)
+		if err != nil {
+			return err
+		}
+		err = checkIfMatch(tx, r, ref)
+		if err != nil {
+			return err
+		}
+		paused = isPaused(tx, ref)
+		err = checkUniqueName(tx, ref.namespace, opts.Name, ref.id)
+		if err != nil {
+			return err
+		}
+		err = putTap(tx, ref.path(), opts, transpiled)
+		if err != nil {
+			return err
+		}
+		rev = readRevision(tx, ref.path())
+		return appendAudit(tx, r, data.AuditUpdate, ref, old, opts)
+	})
+
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+		log.Error(err, "tap not found")
+		return
+	}
+
+	if errors.Is(err, ErrPreconditionFailed) {
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		log.Error(err, "revision mismatch")
+		return
+	}
+
+	if errors.Is(err, ErrConflict) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		log.Error(err, "conflicting tap name")
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not update tap: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not update tap")
+		return
+	}
+
+	if !paused {
+		err = s.restartTap(log, ref)
+		if err != nil {
+			http.Error(w, fmt.Errorf("could not restart tap: %w", err).Error(), http.StatusInternalServerError)
+			log.Error(err, "could not restart tap")
+			return
+		}
+	}
+
+	w.Header().Set("etag", etagOf(rev))
+	w.WriteHeader(http.StatusNoContent)
+}
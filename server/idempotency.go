@@ -0,0 +1,101 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+)
+
+// idempotencyKeyTTL is how long a retried create returns the tap of the first request.
+const idempotencyKeyTTL = 24 * time.Hour
+
+var ErrIdempotencyKeyReused = errors.New("idempotency key was used for a different request")
+
+type idempotencyRecord struct {
+	TapID       string    `json:"tap_id"`
+	RequestHash string    `json:"request_hash"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func idempotencyKeysPathOf(ns string) dbpath.Path {
+	return namespacesPath.Append(ns, "idempotency_keys")
+}
+
+// idempotencyKeyPath hashes the key, so clients can use any characters in it.
+func idempotencyKeyPath(ns, key string) dbpath.Path {
+	h := sha256.Sum256([]byte(key))
+	return idempotencyKeysPathOf(ns).Append(hex.EncodeToString(h[:]))
+}
+
+func hashRequest(req any) (string, error) {
+	d, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("could not marshal request: %w", err)
+	}
+	h := sha256.Sum256(d)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// createdWithKey returns the ID of the tap created with the idempotency key.
+// It returns an empty ID when the key is unused, has expired or its tap was
+// deleted since.
+func createdWithKey(tx bolted.SugaredReadTx, ns, key, requestHash string, now time.Time) (string, error) {
+	kp := idempotencyKeyPath(ns, key)
+	if !tx.Exists(kp) {
+		return "", nil
+	}
+
+	rec := idempotencyRecord{}
+	err := json.Unmarshal(tx.Get(kp), &rec)
+	if err != nil {
+		return "", fmt.Errorf("could not parse idempotency record: %w", err)
+	}
+
+	if now.Sub(rec.CreatedAt) > idempotencyKeyTTL || !tx.Exists(tapRef{namespace: ns, id: rec.TapID}.path()) {
+		return "", nil
+	}
+
+	if rec.RequestHash != requestHash {
+		return "", ErrIdempotencyKeyReused
+	}
+
+	return rec.TapID, nil
+}
+
+// putIdempotencyKey remembers the tap created with the key and drops expired keys of the namespace.
+func putIdempotencyKey(tx bolted.SugaredWriteTx, ns, key, requestHash, tapID string, now time.Time) error {
+	keysPath := idempotencyKeysPathOf(ns)
+	if !tx.Exists(keysPath) {
+		tx.CreateMap(keysPath)
+	}
+
+	expired := []string{}
+	for it := tx.Iterator(keysPath); !it.IsDone(); it.Next() {
+		rec := idempotencyRecord{}
+		err := json.Unmarshal(it.GetValue(), &rec)
+		if err != nil || now.Sub(rec.CreatedAt) > idempotencyKeyTTL {
+			expired = append(expired, it.GetKey())
+		}
+	}
+	for _, k := range expired {
+		tx.Delete(keysPath.Append(k))
+	}
+
+	d, err := json.Marshal(idempotencyRecord{
+		TapID:       tapID,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+	})
+	if err != nil {
+		return fmt.Errorf("could not marshal idempotency record: %w", err)
+	}
+
+	tx.Put(idempotencyKeyPath(ns, key), d)
+	return nil
+}
@@ -2,6 +2,7 @@ package server_test
 
 import (
 	"context"
+	"sync/atomic"
 
 	"github.com/draganm/event-buffer/client"
 	tapClient "github.com/draganm/event-tap/client"
@@ -14,11 +15,27 @@ const stateKey = StateKeyType("")
 
 type State struct {
 	bufferClient  *client.Client
+	bufferURL     string
 	tapClient     *tapClient.Client
 	webhookClient *client.Client
 	webhookURL    string
 	listResult    []data.TapListEntry
 	createdTapID  string
+	requestErr    error
+	testResult    *data.TapTestResult
+	adminClient   *tapClient.Client
+	token         *data.CreatedToken
+	applyResult   *data.ApplyResult
+	archive       *data.Archive
+	importResult  *data.ImportResult
+	newTapClient  *tapClient.Client
+	snapshotDir   string
+	createdIDs    []string
+	revision      uint64
+	receiverURL   string
+	received      *atomic.Int64
+	requestURIs   chan string
+	batchSizes    chan int
 }
 
 func getState(ctx context.Context) *State {
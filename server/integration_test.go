@@ -2,21 +2,34 @@ package server_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/cucumber/godog"
+	"github.com/draganm/bolted/embedded"
 	"github.com/draganm/event-buffer/client"
 	tapClient "github.com/draganm/event-tap/client"
 	"github.com/draganm/event-tap/data"
+	"github.com/draganm/event-tap/reconcile"
+	"github.com/draganm/event-tap/server"
 	"github.com/draganm/event-tap/server/testrig"
 	"github.com/go-logr/logr"
 	"github.com/go-logr/zapr"
 	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/pflag"
 	"go.uber.org/zap"
 )
@@ -88,10 +101,11 @@ func InitializeScenario(ctx *godog.ScenarioContext) {
 		}
 
 		state.bufferClient = bufferClient
+		state.bufferURL = bufferServerURL
 
 		// tap
 
-		tapServerURL, err := testrig.StartServer(ctx, log, bufferServerURL)
+		tapServerURL, err := testrig.StartServerWithConfig(ctx, log, bufferServerURL, server.Config{LookupAllowedHosts: testLookupHosts})
 		if err != nil {
 			return ctx, fmt.Errorf("could not create tap client: %w", err)
 		}
@@ -136,6 +150,140 @@ func InitializeScenario(ctx *godog.ScenarioContext) {
 	ctx.Step(`^the result should have one tap$`, theResultShouldHaveOneTap)
 	ctx.Step(`^I delete the tap$`, iDeleteTheTap)
 	ctx.Step(`^the list of taps should not contain the deleted tap$`, theListOfTapsShouldNotContainTheDeletedTap)
+	ctx.Step(`^there is one tap looping forever on an event$`, thereIsOneTapLoopingForeverOnAnEvent)
+	ctx.Step(`^there is one tap delivering to a receiver that never responds$`, thereIsOneTapDeliveringToAReceiverThatNeverResponds)
+	ctx.Step(`^I create a new map of events with a templated webhook url$`, iCreateANewMapOfEventsWithATemplatedWebhookUrl)
+	ctx.Step(`^the receiver should receive the mapped event on the resolved url$`, theReceiverShouldReceiveTheMappedEventOnTheResolvedUrl)
+	ctx.Step(`^I limit the tap to (\d+) events per second$`, iLimitTheTapToEventsPerSecond)
+	ctx.Step(`^I limit a missing tap$`, iLimitAMissingTap)
+	ctx.Step(`^the request should fail$`, theRequestShouldFail)
+	ctx.Step(`^the events "([^"]*)" and "([^"]*)" in the buffer$`, theEventsInTheBuffer)
+	ctx.Step(`^I create a new map of events filtering out "([^"]*)"$`, iCreateANewMapOfEventsFilteringOut)
+	ctx.Step(`^the receiver should only receive "([^"]*)"$`, theReceiverShouldOnlyReceive)
+	ctx.Step(`^I create a new map of events logging "([^"]*)" to the console$`, iCreateANewMapOfEventsLoggingToTheConsole)
+	ctx.Step(`^the logs of the tap should contain "([^"]*)"$`, theLogsOfTheTapShouldContain)
+	ctx.Step(`^I pause and resume the tap$`, iPauseAndResumeTheTap)
+	ctx.Step(`^I pause the tap$`, iPauseTheTap)
+	ctx.Step(`^the logs of the tap on the new server should be empty$`, theLogsOfTheTapOnTheNewServerShouldBeEmpty)
+	ctx.Step(`^I create a new map of events counting events in the state$`, iCreateANewMapOfEventsCountingEventsInTheState)
+	ctx.Step(`^the receiver should receive the event with count (\d+)$`, theReceiverShouldReceiveTheEventWithCount)
+	ctx.Step(`^events with event times ((?:\d+, )*\d+ and \d+) in the buffer$`, eventsWithEventTimesInTheBuffer)
+	ctx.Step(`^I create a new map of events counting events in tumbling windows of (\d+)ms$`, iCreateANewMapOfEventsCountingEventsInTumblingWindows)
+	ctx.Step(`^the receiver should receive the window from (\d+) to (\d+) with count (\d+)$`, theReceiverShouldReceiveTheWindowWithCount)
+	ctx.Step(`^I create a new map of events counting events in wall clock windows of (\d+)ms$`, iCreateANewMapOfEventsCountingEventsInWallClockWindows)
+	ctx.Step(`^the receiver should receive a wall clock window with count (\d+)$`, theReceiverShouldReceiveAWallClockWindowWithCount)
+	ctx.Step(`^I create a new map of events counting events in sliding windows of (\d+)ms every (\d+)ms$`, iCreateANewMapOfEventsCountingEventsInSlidingWindows)
+	ctx.Step(`^the receiver should receive the windows$`, theReceiverShouldReceiveTheWindows)
+	ctx.Step(`^I create a tap named "([^"]*)" counting events in tumbling windows of (\d+)ms allowing (\d+)ms of lateness$`, iCreateATapNamedCountingEventsInTumblingWindowsAllowingLateness)
+	ctx.Step(`^the tap named "([^"]*)" should have dropped (\d+) late events?$`, theTapNamedShouldHaveDroppedLateEvents)
+	ctx.Step(`^I create a new map of events looking up the buffer over http$`, iCreateANewMapOfEventsLookingUpTheBufferOverHttp)
+	ctx.Step(`^the receiver should receive the event with lookup status (\d+)$`, theReceiverShouldReceiveTheEventWithLookupStatus)
+	ctx.Step(`^I test tap code looking up a host that is not allowed$`, iTestTapCodeLookingUpAHostThatIsNotAllowed)
+	ctx.Step(`^I test tap code looking up a host that only the tap allows$`, iTestTapCodeLookingUpAHostThatOnlyTheTapAllows)
+	ctx.Step(`^I test tap code looking up a url redirecting to a host that is not allowed$`, iTestTapCodeLookingUpAUrlRedirectingToAHostThatIsNotAllowed)
+	ctx.Step(`^I test tap code looking up a url that does not respond in time$`, iTestTapCodeLookingUpAUrlThatDoesNotRespondInTime)
+	ctx.Step(`^the library "([^"]*)" version "([^"]*)" decorating events with "([^"]*)"$`, theLibraryVersionDecoratingEventsWith)
+	ctx.Step(`^I create a new map of events decorating events with the "([^"]*)" library$`, iCreateANewMapOfEventsDecoratingEventsWithTheLibrary)
+	ctx.Step(`^the receiver should receive events decorated with "([^"]*)"$`, theReceiverShouldReceiveEventsDecoratedWith)
+	ctx.Step(`^I create a new map of events written in typescript$`, iCreateANewMapOfEventsWrittenInTypescript)
+	ctx.Step(`^I create a new map of events with invalid typescript$`, iCreateANewMapOfEventsWithInvalidTypescript)
+	ctx.Step(`^I create a new map of events using the (\w+) transform "([^"]*)"$`, iCreateANewMapOfEventsUsingTheTransform)
+	ctx.Step(`^I create a new map of events using a wasm module echoing its input$`, iCreateANewMapOfEventsUsingAWasmModuleEchoingItsInput)
+	ctx.Step(`^I create a new map of events using an invalid wasm module$`, iCreateANewMapOfEventsUsingAnInvalidWasmModule)
+	ctx.Step(`^the receiver should receive that event paired with its id$`, theReceiverShouldReceiveThatEventPairedWithItsId)
+	ctx.Step(`^I create a new map of events waiting for a batch of (\d+) events for at most (\d+)ms$`, iCreateANewMapOfEventsWaitingForABatch)
+	ctx.Step(`^a receiver recording batches$`, aReceiverRecordingBatches)
+	ctx.Step(`^I create a tap delivering to that receiver in batches of at least (\d+) events$`, iCreateATapDeliveringToThatReceiverInBatchesOfAtLeastEvents)
+	ctx.Step(`^I create a tap delivering to that receiver in batches of at least (\d+) bytes$`, iCreateATapDeliveringToThatReceiverInBatchesOfAtLeastBytes)
+	ctx.Step(`^the receiver should receive a batch of (\d+) events$`, theReceiverShouldReceiveABatchOfEvents)
+	ctx.Step(`^I test tap code logging "([^"]*)" with the inline event "([^"]*)"$`, iTestTapCodeLoggingWithTheInlineEvent)
+	ctx.Step(`^I test tap code logging "([^"]*)" with the events from the buffer$`, iTestTapCodeLoggingWithTheEventsFromTheBuffer)
+	ctx.Step(`^I test tap code throwing "([^"]*)"$`, iTestTapCodeThrowing)
+	ctx.Step(`^dry runs limited to (\d+)ms$`, dryRunsLimitedTo)
+	ctx.Step(`^I test tap code that never returns$`, iTestTapCodeThatNeverReturns)
+	ctx.Step(`^the test output should be "([^"]*)"$`, theTestOutputShouldBe)
+	ctx.Step(`^the test logs should contain "([^"]*)"$`, theTestLogsShouldContain)
+	ctx.Step(`^the test error should contain "([^"]*)"$`, theTestErrorShouldContain)
+	ctx.Step(`^there should be no taps$`, thereShouldBeNoTaps)
+	ctx.Step(`^an admin token$`, anAdminToken)
+	ctx.Step(`^a token with the "([^"]*)" scope$`, aTokenWithTheScope)
+	ctx.Step(`^I list the taps without a token$`, iListTheTapsWithoutAToken)
+	ctx.Step(`^I list the taps with that token$`, iListTheTapsWithThatToken)
+	ctx.Step(`^I create a tap with that token$`, iCreateATapWithThatToken)
+	ctx.Step(`^I revoke that token$`, iRevokeThatToken)
+	ctx.Step(`^I create a token without authentication$`, iCreateATokenWithoutAuthentication)
+	ctx.Step(`^the request should succeed$`, theRequestShouldSucceed)
+	ctx.Step(`^the request should fail with status "([^"]*)"$`, theRequestShouldFailWithStatus)
+	ctx.Step(`^there is one tap in the namespace "([^"]*)"$`, thereIsOneTapInTheNamespace)
+	ctx.Step(`^I list the taps of the namespace "([^"]*)"$`, iListTheTapsOfTheNamespace)
+	ctx.Step(`^a token with the "([^"]*)" scope bound to the namespace "([^"]*)"$`, aTokenWithTheScopeBoundToTheNamespace)
+	ctx.Step(`^I list the taps of the namespace "([^"]*)" with that token$`, iListTheTapsOfTheNamespaceWithThatToken)
+	ctx.Step(`^the namespace "([^"]*)" is limited to (\d+) taps?$`, theNamespaceIsLimitedToTaps)
+	ctx.Step(`^I create a tap in the namespace "([^"]*)"$`, iCreateATapInTheNamespace)
+	ctx.Step(`^the namespace "([^"]*)" is limited to (\d+) requests per second$`, theNamespaceIsLimitedToRequestsPerSecond)
+	ctx.Step(`^there are (\d+) taps in the namespace "([^"]*)"$`, thereAreTapsInTheNamespace)
+	ctx.Step(`^the receiver should receive (\d+) webhooks spread over at least (\d+) milliseconds$`, theReceiverShouldReceiveWebhooksSpreadOverAtLeastMilliseconds)
+	ctx.Step(`^(\d+) events in the buffer$`, eventsInTheBuffer)
+	ctx.Step(`^I create a tap named "([^"]*)" limited to (\d+) events per second$`, iCreateATapNamedLimitedToEventsPerSecond)
+	ctx.Step(`^the tap named "([^"]*)" should have waited at least (\d+) milliseconds for its rate limit$`, theTapNamedShouldHaveWaitedAtLeastMillisecondsForItsRateLimit)
+	ctx.Step(`^I publish a library with that token$`, iPublishALibraryWithThatToken)
+	ctx.Step(`^circuit breakers that open after (\d+) failures? for (\d+)ms$`, circuitBreakersThatOpenAfterFailuresFor)
+	ctx.Step(`^a receiver failing the first (\d+) requests?$`, aReceiverFailingTheFirstRequests)
+	ctx.Step(`^there (?:is|are) (\d+) taps? delivering to that receiver$`, thereAreTapsDeliveringToThatReceiver)
+	ctx.Step(`^the tap status should contain "([^"]*)"$`, theTapStatusShouldContain)
+	ctx.Step(`^the receiver should have received (\d+) requests?$`, theReceiverShouldHaveReceivedRequests)
+	ctx.Step(`^the receiver should eventually receive (\d+) requests?$`, theReceiverShouldEventuallyReceiveRequests)
+	ctx.Step(`^I wait (\d+)ms$`, iWaitMs)
+	ctx.Step(`^I create a new map of events with a templated webhook url and the tenant "([^"]*)"$`, iCreateANewMapOfEventsWithATemplatedWebhookUrlAndTheTenant)
+	ctx.Step(`^the receiver should be called on "([^"]*)"$`, theReceiverShouldBeCalledOn)
+	ctx.Step(`^I create a new map of events written in typescript throwing on line (\d+)$`, iCreateANewMapOfEventsWrittenInTypescriptThrowingOnLine)
+	ctx.Step(`^a tap named "([^"]*)"$`, aTapNamed)
+	ctx.Step(`^I export the taps once the cursor and state are stored$`, iExportTheTapsOnceTheCursorAndStateAreStored)
+	ctx.Step(`^I export the taps$`, iExportTheTaps)
+	ctx.Step(`^I import the archive into a new server$`, iImportTheArchiveIntoANewServer)
+	ctx.Step(`^exporting the new server should give the same taps$`, exportingTheNewServerShouldGiveTheSameTaps)
+	ctx.Step(`^the new server should have the library "([^"]*)" at version "([^"]*)"$`, theNewServerShouldHaveTheLibraryAtVersion)
+	ctx.Step(`^I import the archive$`, iImportTheArchive)
+	ctx.Step(`^the id of the exported tap is changed to "([^"]*)"$`, theIdOfTheExportedTapIsChangedTo)
+	ctx.Step(`^I import the archive skipping conflicts$`, iImportTheArchiveSkippingConflicts)
+	ctx.Step(`^the tap should be skipped$`, theTapShouldBeSkipped)
+	ctx.Step(`^I apply with pruning:$`, iApplyWithPruning)
+	ctx.Step(`^I dry run applying with pruning:$`, iDryRunApplyingWithPruning)
+	ctx.Step(`^the apply result should be "([^"]*)"$`, theApplyResultShouldBe)
+	ctx.Step(`^the tap names should be "([^"]*)"$`, theTapNamesShouldBe)
+	ctx.Step(`^I pause the tap with that token$`, iPauseTheTapWithThatToken)
+	ctx.Step(`^I resume the tap with that token$`, iResumeTheTapWithThatToken)
+	ctx.Step(`^I delete the tap with that token$`, iDeleteTheTapWithThatToken)
+	ctx.Step(`^the audit log of the tap should contain "([^"]*)" by that token$`, theAuditLogOfTheTapShouldContainByThatToken)
+	ctx.Step(`^I update the webhook url of the tap$`, iUpdateTheWebhookUrlOfTheTap)
+	ctx.Step(`^the last audit entry of the tap should change "([^"]*)"$`, theLastAuditEntryOfTheTapShouldChange)
+	ctx.Step(`^the last audit entry of the tap should be a "([^"]*)"$`, theLastAuditEntryOfTheTapShouldBeA)
+	ctx.Step(`^I seek the tap to the start of the buffer$`, iSeekTheTapToTheStartOfTheBuffer)
+	ctx.Step(`^the receiver should receive that event twice$`, theReceiverShouldReceiveThatEventTwice)
+	ctx.Step(`^I back up the server$`, iBackUpTheServer)
+	ctx.Step(`^the snapshot should be valid$`, theSnapshotShouldBeValid)
+	ctx.Step(`^I restore the snapshot and start a new server on it$`, iRestoreTheSnapshotAndStartANewServerOnIt)
+	ctx.Step(`^the new server should have the tap$`, theNewServerShouldHaveTheTap)
+	ctx.Step(`^the snapshot is truncated$`, theSnapshotIsTruncated)
+	ctx.Step(`^I write (\d+) scheduled backups keeping (\d+)$`, iWriteScheduledBackupsKeeping)
+	ctx.Step(`^the backup directory should hold (\d+) snapshots$`, theBackupDirectoryShouldHoldSnapshots)
+	ctx.Step(`^restoring the snapshot should fail$`, restoringTheSnapshotShouldFail)
+	ctx.Step(`^I create the tap "([^"]*)" with the idempotency key "([^"]*)"$`, iCreateTheTapWithTheIdempotencyKey)
+	ctx.Step(`^I create the tap "([^"]*)" with the id "([^"]*)"$`, iCreateTheTapWithTheId)
+	ctx.Step(`^both creates should return the same tap$`, bothCreatesShouldReturnTheSameTap)
+	ctx.Step(`^I read the tap with its revision$`, iReadTheTapWithItsRevision)
+	ctx.Step(`^I update the webhook url of the tap at that revision$`, iUpdateTheWebhookUrlOfTheTapAtThatRevision)
+	ctx.Step(`^I delete the tap at that revision$`, iDeleteTheTapAtThatRevision)
+	ctx.Step(`^the tap should be at revision (\d+)$`, theTapShouldBeAtRevision)
+	ctx.Step(`^I roll the tap back to revision (\d+)$`, iRollTheTapBackToRevision)
+	ctx.Step(`^a tap named "([^"]*)" labelled "([^"]*)"$`, aTapNamedLabelled)
+	ctx.Step(`^the tap named "([^"]*)" is paused$`, theTapNamedIsPaused)
+	ctx.Step(`^I list the taps selecting "([^"]*)"$`, iListTheTapsSelecting)
+	ctx.Step(`^I list the (running|paused|failing) taps$`, iListTheTapsWithStatus)
+	ctx.Step(`^I list the taps with names starting with "([^"]*)"$`, iListTheTapsWithNamesStartingWith)
+	ctx.Step(`^the listed taps should be "([^"]*)"$`, theListedTapsShouldBe)
+	ctx.Step(`^the tap should have (\d+) revisions$`, theTapShouldHaveRevisions)
+	ctx.Step(`^revision (\d+) of the tap should have the options of revision (\d+)$`, revisionOfTheTapShouldHaveTheOptionsOfRevision)
 
 }
 
@@ -233,6 +381,80 @@ func iDeleteTheTap(ctx context.Context) error {
 	return s.tapClient.Delete(ctx, s.createdTapID)
 }
 
+func thereIsOneTapLoopingForeverOnAnEvent(ctx context.Context) error {
+	s := getState(ctx)
+	id, err := s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name: "tap1",
+		Code: `
+			function mapEvents(evts){
+				console.log("looping")
+				while(true){}
+			}
+		`,
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+	s.createdTapID = id
+
+	err = s.bufferClient.SendEvents(ctx, []any{"evt1"})
+	if err != nil {
+		return fmt.Errorf("could not send event: %w", err)
+	}
+
+	for {
+		entries, err := s.tapClient.Logs(ctx, id, 0)
+		if err != nil {
+			return fmt.Errorf("could not get logs: %w", err)
+		}
+		if len(entries) > 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func thereIsOneTapDeliveringToAReceiverThatNeverResponds(ctx context.Context) error {
+	s := getState(ctx)
+	received := make(chan struct{}, 1)
+	receiver := startLookupServer(ctx, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+		<-r.Context().Done()
+	})
+
+	id, err := s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name:       "tap1",
+		Code:       `function mapEvents(evts){return evts.map(([id, evt]) => evt)}`,
+		WebhookURL: receiver.URL,
+		BatchLimit: 20,
+	})
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+	s.createdTapID = id
+
+	err = s.bufferClient.SendEvents(ctx, []any{"evt1"})
+	if err != nil {
+		return fmt.Errorf("could not send event: %w", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-received:
+		return nil
+	}
+}
+
 func theListOfTapsShouldNotContainTheDeletedTap(ctx context.Context) error {
 	s := getState(ctx)
 	listResult, err := s.tapClient.List(ctx)
@@ -246,3 +468,1943 @@ func theListOfTapsShouldNotContainTheDeletedTap(ctx context.Context) error {
 
 	return nil
 }
+
+func iCreateANewMapOfEventsWithATemplatedWebhookUrl(ctx context.Context) error {
+	s := getState(ctx)
+	templatedURL := strings.TrimSuffix(s.webhookURL, "events") + "{{.path}}"
+	_, err := s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name:       "tap1",
+		Code:       `function mapEvents(evts){return evts.map(([id, evt]) => ({path: "events", evt}))}`,
+		WebhookURL: templatedURL,
+		BatchLimit: 20,
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+
+	return nil
+}
+
+func iCreateANewMapOfEventsWithATemplatedWebhookUrlAndTheTenant(ctx context.Context, tenant string) error {
+	s := getState(ctx)
+	s.requestURIs = make(chan string, 10)
+	receiver := startLookupServer(ctx, func(w http.ResponseWriter, r *http.Request) {
+		s.requestURIs <- r.RequestURI
+	})
+
+	_, err := s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name:       "tap1",
+		Code:       fmt.Sprintf(`function mapEvents(evts){return evts.map(([id, evt]) => ({tenant: %q, evt}))}`, tenant),
+		WebhookURL: receiver.URL + "/tenants/{{.tenant}}/events",
+		BatchLimit: 20,
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+
+	return nil
+}
+
+func theReceiverShouldBeCalledOn(ctx context.Context, requestURI string) error {
+	s := getState(ctx)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case uri := <-s.requestURIs:
+		if uri != requestURI {
+			return fmt.Errorf("expected the receiver to be called on %s, got %s", requestURI, uri)
+		}
+		return nil
+	}
+}
+
+func theReceiverShouldReceiveTheMappedEventOnTheResolvedUrl(ctx context.Context) error {
+	s := getState(ctx)
+	evts := []any{}
+	_, err := s.webhookClient.PollForEvents(ctx, "", 1, &evts)
+	if err != nil {
+		return fmt.Errorf("failed polling for webhook events: %w", err)
+	}
+	diff := cmp.Diff(evts, []any{map[string]any{"path": "events", "evt": "evt1"}})
+	if diff != "" {
+		return fmt.Errorf("diff:\n%s", diff)
+	}
+	return nil
+}
+
+func iCreateANewMapOfEventsWaitingForABatch(ctx context.Context, size, waitMillis int) error {
+	s := getState(ctx)
+	_, err := s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name:         "tap1",
+		Code:         `function mapEvents(evts){return evts.map(([id, evt]) => evt)}`,
+		WebhookURL:   s.webhookURL,
+		BatchLimit:   20,
+		MinBatchSize: size,
+		MaxBatchWait: (time.Duration(waitMillis) * time.Millisecond).String(),
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+
+	return nil
+}
+
+func aReceiverRecordingBatches(ctx context.Context) error {
+	s := getState(ctx)
+	s.received = &atomic.Int64{}
+	s.batchSizes = make(chan int, 10)
+	receiver := startLookupServer(ctx, func(w http.ResponseWriter, r *http.Request) {
+		s.received.Add(1)
+		batch := []any{}
+		err := json.NewDecoder(r.Body).Decode(&batch)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.batchSizes <- len(batch)
+	})
+	s.receiverURL = receiver.URL
+	return nil
+}
+
+func createBatchingTap(ctx context.Context, opts tapClient.CreateTapOptions) error {
+	s := getState(ctx)
+	opts.Name = "tap1"
+	opts.Code = `function mapEvents(evts){return evts.map(([id, evt]) => evt)}`
+	opts.WebhookURL = s.receiverURL
+	opts.BatchLimit = 20
+
+	id, err := s.tapClient.CreateTap(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+
+	s.createdTapID = id
+
+	return nil
+}
+
+func iCreateATapDeliveringToThatReceiverInBatchesOfAtLeastEvents(ctx context.Context, size int) error {
+	return createBatchingTap(ctx, tapClient.CreateTapOptions{MinBatchSize: size})
+}
+
+func iCreateATapDeliveringToThatReceiverInBatchesOfAtLeastBytes(ctx context.Context, bytes int) error {
+	return createBatchingTap(ctx, tapClient.CreateTapOptions{MaxBatchBytes: bytes})
+}
+
+func theReceiverShouldReceiveABatchOfEvents(ctx context.Context, size int) error {
+	s := getState(ctx)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case received := <-s.batchSizes:
+		if received != size {
+			return fmt.Errorf("expected a batch of %d events, got %d", size, received)
+		}
+		return nil
+	}
+}
+
+func iLimitTheTapToEventsPerSecond(ctx context.Context, eventsPerSecond int) error {
+	s := getState(ctx)
+	return s.tapClient.SetRateLimit(ctx, s.createdTapID, data.RateLimit{
+		EventsPerSecond: float64(eventsPerSecond),
+	})
+}
+
+func iLimitAMissingTap(ctx context.Context) error {
+	s := getState(ctx)
+	s.requestErr = s.tapClient.SetRateLimit(ctx, "missing", data.RateLimit{
+		RequestsPerSecond: 1,
+	})
+	return nil
+}
+
+func theRequestShouldFail(ctx context.Context) error {
+	s := getState(ctx)
+	if s.requestErr == nil {
+		return fmt.Errorf("expected request to fail")
+	}
+	return nil
+}
+
+func theEventsInTheBuffer(ctx context.Context, evt1, evt2 string) error {
+	s := getState(ctx)
+	err := s.bufferClient.SendEvents(ctx, []any{evt1, evt2})
+	if err != nil {
+		return fmt.Errorf("could not send events: %w", err)
+	}
+	return nil
+}
+
+func iCreateANewMapOfEventsFilteringOut(ctx context.Context, rejected string) error {
+	s := getState(ctx)
+	_, err := s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name: "tap1",
+		Code: fmt.Sprintf(`
+			function filterEvent(id, evt){return evt !== %q}
+			function mapEvents(evts){return evts.map(([id, evt]) => evt)}
+		`, rejected),
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+
+	return nil
+}
+
+func theReceiverShouldOnlyReceive(ctx context.Context, expected string) error {
+	s := getState(ctx)
+	evts := []any{}
+	_, err := s.webhookClient.PollForEvents(ctx, "", 10, &evts)
+	if err != nil {
+		return fmt.Errorf("failed polling for webhook events: %w", err)
+	}
+	diff := cmp.Diff(evts, []any{expected})
+	if diff != "" {
+		return fmt.Errorf("diff:\n%s", diff)
+	}
+	return nil
+}
+
+func iCreateANewMapOfEventsLoggingToTheConsole(ctx context.Context, message string) error {
+	s := getState(ctx)
+	id, err := s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name:       "tap1",
+		Code:       fmt.Sprintf(`function mapEvents(evts){console.log(%q, evts.length); return evts.map(([id, evt]) => evt)}`, message),
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+
+	s.createdTapID = id
+
+	return nil
+}
+
+func theLogsOfTheTapShouldContain(ctx context.Context, message string) error {
+	s := getState(ctx)
+	entries, err := s.tapClient.Logs(ctx, s.createdTapID, 0)
+	if err != nil {
+		return fmt.Errorf("could not get logs: %w", err)
+	}
+	for _, e := range entries {
+		if e.Message == message {
+			return nil
+		}
+	}
+	return fmt.Errorf("logs %v do not contain %q", entries, message)
+}
+
+func iPauseAndResumeTheTap(ctx context.Context) error {
+	s := getState(ctx)
+	err := s.tapClient.PauseTap(ctx, s.createdTapID)
+	if err != nil {
+		return fmt.Errorf("could not pause tap: %w", err)
+	}
+	err = s.tapClient.ResumeTap(ctx, s.createdTapID)
+	if err != nil {
+		return fmt.Errorf("could not resume tap: %w", err)
+	}
+	return nil
+}
+
+func iPauseTheTap(ctx context.Context) error {
+	s := getState(ctx)
+	return s.tapClient.PauseTap(ctx, s.createdTapID)
+}
+
+func theLogsOfTheTapOnTheNewServerShouldBeEmpty(ctx context.Context) error {
+	s := getState(ctx)
+	entries, err := s.newTapClient.Logs(ctx, s.createdTapID, 0)
+	if err != nil {
+		return fmt.Errorf("could not get logs: %w", err)
+	}
+	if len(entries) != 0 {
+		return fmt.Errorf("expected no logs, got %v", entries)
+	}
+	return nil
+}
+
+func iCreateANewMapOfEventsCountingEventsInTheState(ctx context.Context) error {
+	s := getState(ctx)
+	_, err := s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name: "tap1",
+		Code: `
+			function mapEvents(evts){
+				const count = (state.get("count") || 0) + evts.length
+				state.put("count", count)
+				return evts.map(([id, evt]) => ({evt, count}))
+			}
+		`,
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+
+	return nil
+}
+
+func theReceiverShouldReceiveTheEventWithCount(ctx context.Context, count int) error {
+	s := getState(ctx)
+	evts := []any{}
+	_, err := s.webhookClient.PollForEvents(ctx, "", 1, &evts)
+	if err != nil {
+		return fmt.Errorf("failed polling for webhook events: %w", err)
+	}
+	diff := cmp.Diff(evts, []any{map[string]any{"evt": "evt1", "count": float64(count)}})
+	if diff != "" {
+		return fmt.Errorf("diff:\n%s", diff)
+	}
+	return nil
+}
+
+func eventsWithEventTimesInTheBuffer(ctx context.Context, times string) error {
+	s := getState(ctx)
+	evts := []any{}
+	for _, t := range strings.Split(strings.ReplaceAll(times, " and ", ", "), ", ") {
+		ms, err := strconv.Atoi(t)
+		if err != nil {
+			return fmt.Errorf("could not parse event time: %w", err)
+		}
+		evts = append(evts, map[string]any{"t": ms})
+	}
+	err := s.bufferClient.SendEvents(ctx, evts)
+	if err != nil {
+		return fmt.Errorf("could not send events: %w", err)
+	}
+	return nil
+}
+
+func iCreateANewMapOfEventsCountingEventsInTumblingWindows(ctx context.Context, sizeMillis int) error {
+	s := getState(ctx)
+	_, err := s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name: "tap1",
+		Code: fmt.Sprintf(`
+			const counts = windows.tumbling("counts", {size: %d})
+			function mapEvents(evts){
+				evts.forEach(([id, evt]) => counts.add("all", (acc) => (acc || 0) + 1, evt.t))
+				return []
+			}
+		`, sizeMillis),
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+
+	return nil
+}
+
+func theReceiverShouldReceiveTheWindowWithCount(ctx context.Context, start, end, count int) error {
+	s := getState(ctx)
+	evts := []any{}
+	_, err := s.webhookClient.PollForEvents(ctx, "", 10, &evts)
+	if err != nil {
+		return fmt.Errorf("failed polling for webhook events: %w", err)
+	}
+	diff := cmp.Diff(evts, []any{map[string]any{
+		"window": "counts",
+		"key":    "all",
+		"start":  float64(start),
+		"end":    float64(end),
+		"value":  float64(count),
+	}})
+	if diff != "" {
+		return fmt.Errorf("diff:\n%s", diff)
+	}
+	return nil
+}
+
+func iCreateANewMapOfEventsCountingEventsInSlidingWindows(ctx context.Context, sizeMillis, slideMillis int) error {
+	s := getState(ctx)
+	_, err := s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name: "tap1",
+		Code: fmt.Sprintf(`
+			const counts = windows.sliding("counts", {size: %d, slide: %d})
+			function mapEvents(evts){
+				evts.forEach(([id, evt]) => counts.add("all", (acc) => (acc || 0) + 1, evt.t))
+				return []
+			}
+		`, sizeMillis, slideMillis),
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+
+	return nil
+}
+
+func theReceiverShouldReceiveTheWindows(ctx context.Context, table *godog.Table) error {
+	s := getState(ctx)
+	expected := []any{}
+	for _, row := range table.Rows[1:] {
+		values := make([]float64, len(row.Cells))
+		for i, c := range row.Cells {
+			v, err := strconv.ParseFloat(c.Value, 64)
+			if err != nil {
+				return fmt.Errorf("could not parse %q: %w", c.Value, err)
+			}
+			values[i] = v
+		}
+		expected = append(expected, map[string]any{
+			"window": "counts",
+			"key":    "all",
+			"start":  values[0],
+			"end":    values[1],
+			"value":  values[2],
+		})
+	}
+
+	evts := []any{}
+	lastID := ""
+	for len(evts) < len(expected) {
+		polled := []any{}
+		ids, err := s.webhookClient.PollForEvents(ctx, lastID, len(expected)-len(evts), &polled)
+		if err != nil {
+			return fmt.Errorf("failed polling for webhook events: %w", err)
+		}
+		evts = append(evts, polled...)
+		lastID = ids[len(ids)-1]
+	}
+
+	diff := cmp.Diff(evts, expected)
+	if diff != "" {
+		return fmt.Errorf("diff:\n%s", diff)
+	}
+	return nil
+}
+
+func iCreateATapNamedCountingEventsInTumblingWindowsAllowingLateness(ctx context.Context, name string, sizeMillis, latenessMillis int) error {
+	s := getState(ctx)
+	_, err := s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name: name,
+		Code: fmt.Sprintf(`
+			const counts = windows.tumbling("counts", {size: %d, allowedLateness: %d})
+			function mapEvents(evts){
+				evts.forEach(([id, evt]) => counts.add("all", (acc) => (acc || 0) + 1, evt.t))
+				return []
+			}
+		`, sizeMillis, latenessMillis),
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+
+	return nil
+}
+
+func theTapNamedShouldHaveDroppedLateEvents(ctx context.Context, name string, count int) error {
+	dropped, err := tapMetric("tap_window_late_events_total", name)
+	if err != nil {
+		return err
+	}
+
+	if dropped != float64(count) {
+		return fmt.Errorf("expected %d late events to be dropped, got %.0f", count, dropped)
+	}
+	return nil
+}
+
+func iCreateANewMapOfEventsCountingEventsInWallClockWindows(ctx context.Context, sizeMillis int) error {
+	s := getState(ctx)
+	_, err := s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name: "tap1",
+		Code: fmt.Sprintf(`
+			const counts = windows.tumbling("counts", {size: %d, time: "wall"})
+			function mapEvents(evts){
+				evts.forEach(([id, evt]) => counts.add("all", (acc) => (acc || 0) + 1))
+				return []
+			}
+		`, sizeMillis),
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+
+	return nil
+}
+
+func theReceiverShouldReceiveAWallClockWindowWithCount(ctx context.Context, count int) error {
+	s := getState(ctx)
+	evts := []map[string]any{}
+	_, err := s.webhookClient.PollForEvents(ctx, "", 10, &evts)
+	if err != nil {
+		return fmt.Errorf("failed polling for webhook events: %w", err)
+	}
+	if len(evts) != 1 {
+		return fmt.Errorf("expected one window, got %v", evts)
+	}
+	if evts[0]["value"] != float64(count) {
+		return fmt.Errorf("expected count %d, got %v", count, evts[0]["value"])
+	}
+	return nil
+}
+
+func iCreateANewMapOfEventsLookingUpTheBufferOverHttp(ctx context.Context) error {
+	s := getState(ctx)
+	bu, err := url.Parse(s.bufferURL)
+	if err != nil {
+		return fmt.Errorf("could not parse buffer url: %w", err)
+	}
+	_, err = s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name: "tap1",
+		Code: fmt.Sprintf(`
+			function mapEvents(evts){
+				const res = http.get(%q)
+				return evts.map(([id, evt]) => ({evt, status: res.status, lookup: res.json()}))
+			}
+		`, s.bufferURL+"/events?limit=1"),
+		WebhookURL:       s.webhookURL,
+		BatchLimit:       20,
+		ExecutionTimeout: "1s",
+		HTTP: &data.HTTPOptions{
+			AllowedHosts: []string{bu.Hostname()},
+			CacheTTL:     "1m",
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+
+	return nil
+}
+
+// testLookupHosts are the hosts the servers of the scenarios allow lookups to.
+var testLookupHosts = []string{"127.0.0.1"}
+
+// startLookupServer starts a server for requests of the tap that runs until the scenario ends.
+func startLookupServer(ctx context.Context, h http.HandlerFunc) *httptest.Server {
+	ls := httptest.NewServer(h)
+	go func() {
+		<-ctx.Done()
+		ls.Close()
+	}()
+	return ls
+}
+
+func testLookup(ctx context.Context, lookupURL string, opts *data.HTTPOptions) error {
+	s := getState(ctx)
+	res, err := s.tapClient.TestTap(ctx, data.TapTestRequest{
+		Options: data.TapOptions{
+			Name: "tap1",
+			Code: fmt.Sprintf(`
+				function mapEvents(evts){
+					const res = http.get(%q)
+					return evts.map(([id, evt]) => res.status)
+				}
+			`, lookupURL),
+			HTTP: opts,
+		},
+		Events: []data.SampleEvent{{Event: "evt1"}},
+	})
+	if err != nil {
+		return fmt.Errorf("could not test tap: %w", err)
+	}
+	s.testResult = res
+	return nil
+}
+
+func iTestTapCodeLookingUpAHostThatIsNotAllowed(ctx context.Context) error {
+	ls := startLookupServer(ctx, func(w http.ResponseWriter, r *http.Request) {})
+	return testLookup(ctx, ls.URL, &data.HTTPOptions{AllowedHosts: []string{"example.com"}})
+}
+
+func iTestTapCodeLookingUpAHostThatOnlyTheTapAllows(ctx context.Context) error {
+	ls := startLookupServer(ctx, func(w http.ResponseWriter, r *http.Request) {})
+	lu, err := url.Parse(ls.URL)
+	if err != nil {
+		return fmt.Errorf("could not parse lookup url: %w", err)
+	}
+	// the same server under a name that is not on the allow list of the server
+	lu.Host = "localhost:" + lu.Port()
+	return testLookup(ctx, lu.String(), &data.HTTPOptions{AllowedHosts: []string{"127.0.0.1", "localhost"}})
+}
+
+func iTestTapCodeLookingUpAUrlRedirectingToAHostThatIsNotAllowed(ctx context.Context) error {
+	target := startLookupServer(ctx, func(w http.ResponseWriter, r *http.Request) {})
+	tu, err := url.Parse(target.URL)
+	if err != nil {
+		return fmt.Errorf("could not parse lookup url: %w", err)
+	}
+	// the same server under a name that is not on the allow list
+	tu.Host = "localhost:" + tu.Port()
+
+	ls := startLookupServer(ctx, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, tu.String(), http.StatusFound)
+	})
+	return testLookup(ctx, ls.URL, &data.HTTPOptions{AllowedHosts: []string{"127.0.0.1"}})
+}
+
+func iTestTapCodeLookingUpAUrlThatDoesNotRespondInTime(ctx context.Context) error {
+	ls := startLookupServer(ctx, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	return testLookup(ctx, ls.URL, &data.HTTPOptions{AllowedHosts: []string{"127.0.0.1"}, Timeout: "50ms"})
+}
+
+func theReceiverShouldReceiveTheEventWithLookupStatus(ctx context.Context, status int) error {
+	s := getState(ctx)
+	evts := []map[string]any{}
+	_, err := s.webhookClient.PollForEvents(ctx, "", 1, &evts)
+	if err != nil {
+		return fmt.Errorf("failed polling for webhook events: %w", err)
+	}
+	if len(evts) != 1 {
+		return fmt.Errorf("expected one event, got %v", evts)
+	}
+	if evts[0]["status"] != float64(status) {
+		return fmt.Errorf("expected status %d, got %v", status, evts[0])
+	}
+	return nil
+}
+
+func theLibraryVersionDecoratingEventsWith(ctx context.Context, name, version, decoration string) error {
+	s := getState(ctx)
+	return s.tapClient.PublishLibrary(ctx, data.Library{
+		Name:    name,
+		Version: version,
+		Code:    fmt.Sprintf(`exports.decorate = (evt) => ({decoration: %q, evt})`, decoration),
+	})
+}
+
+func iCreateANewMapOfEventsDecoratingEventsWithTheLibrary(ctx context.Context, library string) error {
+	s := getState(ctx)
+	_, err := s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name: "tap1",
+		Code: fmt.Sprintf(`
+			const lib = require(%q)
+			function mapEvents(evts){return evts.map(([id, evt]) => lib.decorate(evt))}
+		`, library),
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+
+	return nil
+}
+
+func theReceiverShouldReceiveEventsDecoratedWith(ctx context.Context, decorations string) error {
+	s := getState(ctx)
+	expected := strings.Split(decorations, ",")
+	received := []string{}
+	lastID := ""
+	for len(received) < len(expected) {
+		evts := []map[string]any{}
+		ids, err := s.webhookClient.PollForEvents(ctx, lastID, 10, &evts)
+		if err != nil {
+			return fmt.Errorf("failed polling for webhook events: %w", err)
+		}
+		for _, evt := range evts {
+			received = append(received, fmt.Sprint(evt["decoration"]))
+		}
+		lastID = ids[len(ids)-1]
+	}
+	diff := cmp.Diff(received, expected)
+	if diff != "" {
+		return fmt.Errorf("diff:\n%s", diff)
+	}
+	return nil
+}
+
+func iCreateANewMapOfEventsWrittenInTypescript(ctx context.Context) error {
+	s := getState(ctx)
+	_, err := s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name: "tap1",
+		Code: `
+			type Event = [string, unknown]
+			export function mapEvents(evts: Event[]): unknown[] {
+				return evts.map(([id, evt]) => evt)
+			}
+		`,
+		Language:   "typescript",
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+
+	return nil
+}
+
+func iCreateANewMapOfEventsWrittenInTypescriptThrowingOnLine(ctx context.Context, line int) error {
+	s := getState(ctx)
+	// the types before the throwing line are dropped by the transpiler
+	lines := []string{
+		"type Event = [string, unknown]",
+		"",
+		"interface Counted {",
+		"	count: number",
+		"}",
+		"",
+		"export function mapEvents(evts: Event[]): unknown[] {",
+		"	const counted: Counted = {count: evts.length}",
+	}
+	for len(lines) < line-1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, `	throw new Error("boom " + counted.count)`, "}")
+
+	id, err := s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name:       "tap1",
+		Code:       strings.Join(lines, "\n"),
+		Language:   "typescript",
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+
+	s.createdTapID = id
+
+	return nil
+}
+
+func iCreateANewMapOfEventsWithInvalidTypescript(ctx context.Context) error {
+	s := getState(ctx)
+	_, s.requestErr = s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name:       "tap1",
+		Code:       `export function mapEvents(evts: {): unknown[] {`,
+		Language:   "typescript",
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+	return nil
+}
+
+func iCreateANewMapOfEventsUsingTheTransform(ctx context.Context, transform, code string) error {
+	s := getState(ctx)
+	_, s.requestErr = s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name:       "tap1",
+		Code:       code,
+		Transform:  transform,
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+	return nil
+}
+
+// echoWASM is a module returning its input: `alloc` always returns offset
+// 1024 and `map_events(ptr, len)` returns `ptr << 32 | len`.
+var echoWASM = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	// types: (i32) -> i32, (i32, i32) -> i64
+	0x01, 0x0c, 0x02, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e,
+	// functions
+	0x03, 0x03, 0x02, 0x00, 0x01,
+	// memory of one page
+	0x05, 0x03, 0x01, 0x00, 0x01,
+	// exports: memory, alloc, map_events
+	0x07, 0x1f, 0x03,
+	0x06, 'm', 'e', 'm', 'o', 'r', 'y', 0x02, 0x00,
+	0x05, 'a', 'l', 'l', 'o', 'c', 0x00, 0x00,
+	0x0a, 'm', 'a', 'p', '_', 'e', 'v', 'e', 'n', 't', 's', 0x00, 0x01,
+	// code
+	0x0a, 0x14, 0x02,
+	0x05, 0x00, 0x41, 0x80, 0x08, 0x0b,
+	0x0c, 0x00, 0x20, 0x00, 0xad, 0x42, 0x20, 0x86, 0x20, 0x01, 0xad, 0x84, 0x0b,
+}
+
+func iCreateANewMapOfEventsUsingAWasmModuleEchoingItsInput(ctx context.Context) error {
+	s := getState(ctx)
+	_, err := s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name:      "tap1",
+		Transform: "wasm",
+		WASM: &data.WASMOptions{
+			Module:           echoWASM,
+			MemoryLimitPages: 2,
+			Fuel:             10,
+		},
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+
+	return nil
+}
+
+func iCreateANewMapOfEventsUsingAnInvalidWasmModule(ctx context.Context) error {
+	s := getState(ctx)
+	_, s.requestErr = s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name:      "tap1",
+		Transform: "wasm",
+		WASM: &data.WASMOptions{
+			Module: []byte("not wasm"),
+		},
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+	return nil
+}
+
+func theReceiverShouldReceiveThatEventPairedWithItsId(ctx context.Context) error {
+	s := getState(ctx)
+	evts := [][]any{}
+	_, err := s.webhookClient.PollForEvents(ctx, "", 1, &evts)
+	if err != nil {
+		return fmt.Errorf("failed polling for webhook events: %w", err)
+	}
+	if len(evts) != 1 || len(evts[0]) != 2 || evts[0][1] != "evt1" {
+		return fmt.Errorf("unexpected events %v", evts)
+	}
+	return nil
+}
+
+func loggingCode(message string) string {
+	return fmt.Sprintf(`
+		function mapEvents(evts){
+			console.log(%q, evts.length)
+			return evts.map(([id, evt]) => evt)
+		}
+	`, message)
+}
+
+func iTestTapCodeLoggingWithTheInlineEvent(ctx context.Context, message, event string) error {
+	s := getState(ctx)
+	res, err := s.tapClient.TestTap(ctx, data.TapTestRequest{
+		Options: data.TapOptions{
+			Name: "tap1",
+			Code: loggingCode(message),
+		},
+		Events: []data.SampleEvent{{Event: event}},
+	})
+	if err != nil {
+		return fmt.Errorf("could not test tap: %w", err)
+	}
+	s.testResult = res
+	return nil
+}
+
+func iTestTapCodeLoggingWithTheEventsFromTheBuffer(ctx context.Context, message string) error {
+	s := getState(ctx)
+	res, err := s.tapClient.TestTap(ctx, data.TapTestRequest{
+		Options: data.TapOptions{
+			Name: "tap1",
+			Code: loggingCode(message),
+		},
+		FromBuffer: &data.BufferRange{Limit: 10},
+	})
+	if err != nil {
+		return fmt.Errorf("could not test tap: %w", err)
+	}
+	s.testResult = res
+	return nil
+}
+
+func iTestTapCodeThrowing(ctx context.Context, message string) error {
+	s := getState(ctx)
+	res, err := s.tapClient.TestTap(ctx, data.TapTestRequest{
+		Options: data.TapOptions{
+			Name: "tap1",
+			Code: fmt.Sprintf(`
+				function mapEvents(evts){
+					throw new Error(%q)
+				}
+			`, message),
+		},
+		Events: []data.SampleEvent{{Event: "evt1"}},
+	})
+	if err != nil {
+		return fmt.Errorf("could not test tap: %w", err)
+	}
+	s.testResult = res
+	return nil
+}
+
+func dryRunsLimitedTo(ctx context.Context, ms int) error {
+	s := getState(ctx)
+	tapServerURL, err := testrig.StartServerWithConfig(ctx, logr.FromContextOrDiscard(ctx), s.bufferURL, server.Config{
+		DryRunTimeout: time.Duration(ms) * time.Millisecond,
+	})
+	if err != nil {
+		return fmt.Errorf("could not start tap server: %w", err)
+	}
+
+	s.tapClient, err = tapClient.New(tapServerURL)
+	if err != nil {
+		return fmt.Errorf("could not create tap client: %w", err)
+	}
+	return nil
+}
+
+func iTestTapCodeThatNeverReturns(ctx context.Context) error {
+	s := getState(ctx)
+	res, err := s.tapClient.TestTap(ctx, data.TapTestRequest{
+		Options: data.TapOptions{
+			Name: "tap1",
+			Code: `
+				function mapEvents(evts){
+					while(true){}
+				}
+			`,
+		},
+		Events: []data.SampleEvent{{Event: "evt1"}},
+	})
+	if err != nil {
+		return fmt.Errorf("could not test tap: %w", err)
+	}
+	s.testResult = res
+	return nil
+}
+
+func theTestOutputShouldBe(ctx context.Context, event string) error {
+	s := getState(ctx)
+	if s.testResult.Error != "" {
+		return fmt.Errorf("test failed: %s", s.testResult.Error)
+	}
+	diff := cmp.Diff(s.testResult.Output, []any{event})
+	if diff != "" {
+		return fmt.Errorf("diff:\n%s", diff)
+	}
+	return nil
+}
+
+func theTestLogsShouldContain(ctx context.Context, message string) error {
+	s := getState(ctx)
+	for _, e := range s.testResult.Logs {
+		if e.Message == message {
+			return nil
+		}
+	}
+	return fmt.Errorf("logs %v do not contain %q", s.testResult.Logs, message)
+}
+
+func theTestErrorShouldContain(ctx context.Context, message string) error {
+	s := getState(ctx)
+	if !strings.Contains(s.testResult.Error, message) {
+		return fmt.Errorf("error %q does not contain %q", s.testResult.Error, message)
+	}
+	return nil
+}
+
+func thereShouldBeNoTaps(ctx context.Context) error {
+	s := getState(ctx)
+	taps, err := s.tapClient.List(ctx)
+	if err != nil {
+		return fmt.Errorf("could not list taps: %w", err)
+	}
+	if len(taps) != 0 {
+		return fmt.Errorf("expected no taps, got %v", taps)
+	}
+	return nil
+}
+
+const testAdminToken = "test-admin-token"
+
+func anAdminToken(ctx context.Context) error {
+	s := getState(ctx)
+	// authentication is only enabled on a server with an admin token
+	tapServerURL, err := testrig.StartServerWithConfig(ctx, logr.FromContextOrDiscard(ctx), s.bufferURL, server.Config{AdminToken: testAdminToken})
+	if err != nil {
+		return fmt.Errorf("could not start tap server: %w", err)
+	}
+
+	s.tapClient, err = tapClient.New(tapServerURL)
+	if err != nil {
+		return fmt.Errorf("could not create tap client: %w", err)
+	}
+	s.adminClient = s.tapClient.WithToken(testAdminToken)
+	return nil
+}
+
+func iCreateATokenWithoutAuthentication(ctx context.Context) error {
+	s := getState(ctx)
+	_, s.requestErr = s.tapClient.CreateToken(ctx, "test", data.ScopeAdmin)
+	return nil
+}
+
+func aTokenWithTheScope(ctx context.Context, scope string) (err error) {
+	s := getState(ctx)
+	s.token, err = s.adminClient.CreateToken(ctx, "test", scope)
+	if err != nil {
+		return fmt.Errorf("could not create token: %w", err)
+	}
+	return nil
+}
+
+func iListTheTapsWithoutAToken(ctx context.Context) error {
+	s := getState(ctx)
+	_, s.requestErr = s.tapClient.List(ctx)
+	return nil
+}
+
+func iListTheTapsWithThatToken(ctx context.Context) error {
+	s := getState(ctx)
+	_, s.requestErr = s.tapClient.WithToken(s.token.Token).List(ctx)
+	return nil
+}
+
+func iCreateATapWithThatToken(ctx context.Context) error {
+	s := getState(ctx)
+	s.createdTapID, s.requestErr = s.tapClient.WithToken(s.token.Token).CreateTap(ctx, tapClient.CreateTapOptions{
+		Name:       "tap1",
+		Code:       `function mapEvents(evts){ return evts }`,
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+	return nil
+}
+
+func iRevokeThatToken(ctx context.Context) error {
+	s := getState(ctx)
+	err := s.adminClient.RevokeToken(ctx, s.token.ID)
+	if err != nil {
+		return fmt.Errorf("could not revoke token: %w", err)
+	}
+	return nil
+}
+
+func theRequestShouldSucceed(ctx context.Context) error {
+	s := getState(ctx)
+	if s.requestErr != nil {
+		return fmt.Errorf("expected request to succeed: %w", s.requestErr)
+	}
+	return nil
+}
+
+func theRequestShouldFailWithStatus(ctx context.Context, status string) error {
+	s := getState(ctx)
+	if s.requestErr == nil {
+		return fmt.Errorf("expected request to fail")
+	}
+	if !strings.Contains(s.requestErr.Error(), "unexpected status "+status) {
+		return fmt.Errorf("expected status %s, got %w", status, s.requestErr)
+	}
+	return nil
+}
+
+func thereIsOneTapInTheNamespace(ctx context.Context, ns string) error {
+	s := getState(ctx)
+	id, err := s.tapClient.WithNamespace(ns).CreateTap(ctx, tapClient.CreateTapOptions{
+		Name:       "tap1",
+		Code:       `function mapEvents(evts){return evts.map(([id, evt]) => evt)}`,
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+
+	s.createdTapID = id
+
+	return nil
+}
+
+func iListTheTapsOfTheNamespace(ctx context.Context, ns string) (err error) {
+	s := getState(ctx)
+	s.listResult, err = s.tapClient.WithNamespace(ns).List(ctx)
+	if err != nil {
+		return fmt.Errorf("could not list taps: %w", err)
+	}
+	return nil
+}
+
+func aTokenWithTheScopeBoundToTheNamespace(ctx context.Context, scope, ns string) (err error) {
+	s := getState(ctx)
+	s.token, err = s.adminClient.CreateToken(ctx, "test", scope, ns)
+	if err != nil {
+		return fmt.Errorf("could not create token: %w", err)
+	}
+	return nil
+}
+
+func iListTheTapsOfTheNamespaceWithThatToken(ctx context.Context, ns string) error {
+	s := getState(ctx)
+	_, s.requestErr = s.tapClient.WithNamespace(ns).WithToken(s.token.Token).List(ctx)
+	return nil
+}
+
+func theNamespaceIsLimitedToTaps(ctx context.Context, ns string, maxTaps int) error {
+	s := getState(ctx)
+	err := s.tapClient.SetQuota(ctx, ns, data.NamespaceQuota{MaxTaps: maxTaps})
+	if err != nil {
+		return fmt.Errorf("could not set quota: %w", err)
+	}
+	return nil
+}
+
+func theNamespaceIsLimitedToRequestsPerSecond(ctx context.Context, ns string, rps int) error {
+	s := getState(ctx)
+	err := s.tapClient.SetQuota(ctx, ns, data.NamespaceQuota{MaxRequestsPerSecond: float64(rps)})
+	if err != nil {
+		return fmt.Errorf("could not set quota: %w", err)
+	}
+	return nil
+}
+
+func thereAreTapsInTheNamespace(ctx context.Context, count int, ns string) error {
+	s := getState(ctx)
+	for i := 0; i < count; i++ {
+		_, err := s.tapClient.WithNamespace(ns).CreateTap(ctx, tapClient.CreateTapOptions{
+			Name:       fmt.Sprintf("tap%d", i+1),
+			Code:       `function mapEvents(evts){return evts.map(([id, evt]) => evt)}`,
+			WebhookURL: s.webhookURL,
+			BatchLimit: 20,
+		})
+		if err != nil {
+			return fmt.Errorf("could not create tap: %w", err)
+		}
+	}
+	return nil
+}
+
+func theReceiverShouldReceiveWebhooksSpreadOverAtLeastMilliseconds(ctx context.Context, count, ms int) error {
+	s := getState(ctx)
+	started := time.Now()
+	received := 0
+	lastID := ""
+	for received < count {
+		evts := []any{}
+		ids, err := s.webhookClient.PollForEvents(ctx, lastID, 10, &evts)
+		if err != nil {
+			return fmt.Errorf("failed polling for webhook events: %w", err)
+		}
+		received += len(evts)
+		lastID = ids[len(ids)-1]
+	}
+	took := time.Since(started)
+	if took < time.Duration(ms)*time.Millisecond {
+		return fmt.Errorf("expected the webhooks to take at least %dms, took %s", ms, took)
+	}
+	return nil
+}
+
+func eventsInTheBuffer(ctx context.Context, count int) error {
+	s := getState(ctx)
+	evts := []any{}
+	for i := 1; i <= count; i++ {
+		evts = append(evts, fmt.Sprintf("evt%d", i))
+	}
+	err := s.bufferClient.SendEvents(ctx, evts)
+	if err != nil {
+		return fmt.Errorf("could not send events: %w", err)
+	}
+	return nil
+}
+
+func iCreateATapNamedLimitedToEventsPerSecond(ctx context.Context, name string, eventsPerSecond int) error {
+	s := getState(ctx)
+	id, err := s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name:       name,
+		Code:       `function mapEvents(evts){return evts.map(([id, evt]) => evt)}`,
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+		RateLimit: &data.RateLimit{
+			EventsPerSecond: float64(eventsPerSecond),
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+
+	s.createdTapID = id
+
+	return nil
+}
+
+// tapMetric sums the counters, or the observations of histograms, of the metric for the tap.
+func tapMetric(metric, tapName string) (float64, error) {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return 0, fmt.Errorf("could not gather metrics: %w", err)
+	}
+
+	sum := 0.0
+	for _, mf := range families {
+		if mf.GetName() != metric {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "tap" && l.GetValue() == tapName {
+					sum += m.GetCounter().GetValue() + m.GetHistogram().GetSampleSum()
+				}
+			}
+		}
+	}
+	return sum, nil
+}
+
+func theTapNamedShouldHaveWaitedAtLeastMillisecondsForItsRateLimit(ctx context.Context, name string, ms int) error {
+	waited, err := tapMetric("tap_rate_limit_wait_seconds", name)
+	if err != nil {
+		return err
+	}
+
+	if waited*1000 < float64(ms) {
+		return fmt.Errorf("expected the tap to wait at least %dms for its rate limit, waited %.0fms", ms, waited*1000)
+	}
+	return nil
+}
+
+func iPublishALibraryWithThatToken(ctx context.Context) error {
+	s := getState(ctx)
+	s.requestErr = s.tapClient.WithToken(s.token.Token).PublishLibrary(ctx, data.Library{
+		Name:    "decorator",
+		Version: "1.0.0",
+		Code:    `exports.decorate = (evt) => evt`,
+	})
+	return nil
+}
+
+func circuitBreakersThatOpenAfterFailuresFor(ctx context.Context, failures, coolDownMs int) error {
+	s := getState(ctx)
+	tapServerURL, err := testrig.StartServerWithConfig(ctx, logr.FromContextOrDiscard(ctx), s.bufferURL, server.Config{
+		CircuitBreakerFailures: failures,
+		CircuitBreakerCoolDown: time.Duration(coolDownMs) * time.Millisecond,
+	})
+	if err != nil {
+		return fmt.Errorf("could not start tap server: %w", err)
+	}
+
+	s.tapClient, err = tapClient.New(tapServerURL)
+	if err != nil {
+		return fmt.Errorf("could not create tap client: %w", err)
+	}
+	return nil
+}
+
+func aReceiverFailingTheFirstRequests(ctx context.Context, failing int) error {
+	s := getState(ctx)
+	s.received = &atomic.Int64{}
+	receiver := startLookupServer(ctx, func(w http.ResponseWriter, r *http.Request) {
+		if s.received.Add(1) <= int64(failing) {
+			http.Error(w, "failing", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	s.receiverURL = receiver.URL
+	return nil
+}
+
+func thereAreTapsDeliveringToThatReceiver(ctx context.Context, count int) error {
+	s := getState(ctx)
+	for i := 0; i < count; i++ {
+		id, err := s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+			Name:       fmt.Sprintf("tap%d", i+1),
+			Code:       `function mapEvents(evts){return evts.map(([id, evt]) => evt)}`,
+			WebhookURL: s.receiverURL,
+			BatchLimit: 20,
+		})
+		if err != nil {
+			return fmt.Errorf("could not create tap: %w", err)
+		}
+		s.createdTapID = id
+	}
+	return nil
+}
+
+func theTapStatusShouldContain(ctx context.Context, status string) error {
+	s := getState(ctx)
+	for {
+		taps, err := s.tapClient.List(ctx)
+		if err != nil {
+			return fmt.Errorf("could not list taps: %w", err)
+		}
+		for _, t := range taps {
+			if t.ID == s.createdTapID && strings.Contains(t.Status, status) {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("status of the tap does not contain %q: %w", status, ctx.Err())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func theReceiverShouldHaveReceivedRequests(ctx context.Context, count int) error {
+	s := getState(ctx)
+	received := s.received.Load()
+	if received != int64(count) {
+		return fmt.Errorf("expected %d requests, got %d", count, received)
+	}
+	return nil
+}
+
+func theReceiverShouldEventuallyReceiveRequests(ctx context.Context, count int) error {
+	s := getState(ctx)
+	for s.received.Load() < int64(count) {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("expected %d requests, got %d: %w", count, s.received.Load(), ctx.Err())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+func iWaitMs(ctx context.Context, ms int) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Duration(ms) * time.Millisecond):
+		return nil
+	}
+}
+
+func iCreateATapInTheNamespace(ctx context.Context, ns string) error {
+	s := getState(ctx)
+	_, s.requestErr = s.tapClient.WithNamespace(ns).CreateTap(ctx, tapClient.CreateTapOptions{
+		Name:       "tap2",
+		Code:       `function mapEvents(evts){return evts}`,
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+	return nil
+}
+
+func iPauseTheTapWithThatToken(ctx context.Context) error {
+	s := getState(ctx)
+	return s.tapClient.WithToken(s.token.Token).PauseTap(ctx, s.createdTapID)
+}
+
+func iResumeTheTapWithThatToken(ctx context.Context) error {
+	s := getState(ctx)
+	return s.tapClient.WithToken(s.token.Token).ResumeTap(ctx, s.createdTapID)
+}
+
+func iDeleteTheTapWithThatToken(ctx context.Context) error {
+	s := getState(ctx)
+	return s.tapClient.WithToken(s.token.Token).Delete(ctx, s.createdTapID)
+}
+
+func theAuditLogOfTheTapShouldContainByThatToken(ctx context.Context, actions string) error {
+	s := getState(ctx)
+	entries, err := s.adminClient.Audit(ctx, tapClient.AuditQuery{TapID: s.createdTapID})
+	if err != nil {
+		return fmt.Errorf("could not get audit log: %w", err)
+	}
+	logged := []string{}
+	for _, e := range entries {
+		if e.ActorTokenID != s.token.ID {
+			return fmt.Errorf("expected actor %s, got %s", s.token.ID, e.ActorTokenID)
+		}
+		if e.ClientAddress == "" {
+			return fmt.Errorf("entry %d has no client address", e.Seq)
+		}
+		logged = append(logged, e.Action)
+	}
+	diff := cmp.Diff(strings.Split(actions, ", "), logged)
+	if diff != "" {
+		return fmt.Errorf("diff:\n%s", diff)
+	}
+	return nil
+}
+
+func iUpdateTheWebhookUrlOfTheTap(ctx context.Context) error {
+	s := getState(ctx)
+	return s.tapClient.UpdateTap(ctx, s.createdTapID, tapClient.CreateTapOptions{
+		Name:       "tap1",
+		Code:       `function mapEvents(evts){return evts.map(([id, evt]) => evt)}`,
+		WebhookURL: s.webhookURL + "?updated=true",
+		BatchLimit: 20,
+	})
+}
+
+func theLastAuditEntryOfTheTapShouldChange(ctx context.Context, field string) error {
+	s := getState(ctx)
+	entries, err := s.tapClient.Audit(ctx, tapClient.AuditQuery{TapID: s.createdTapID})
+	if err != nil {
+		return fmt.Errorf("could not get audit log: %w", err)
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("audit log is empty")
+	}
+	last := entries[len(entries)-1]
+	if len(last.Changes) != 1 || last.Changes[0].Field != field {
+		return fmt.Errorf("expected only %s to change, got %v", field, last.Changes)
+	}
+	return nil
+}
+
+func theLastAuditEntryOfTheTapShouldBeA(ctx context.Context, action string) error {
+	s := getState(ctx)
+	entries, err := s.tapClient.Audit(ctx, tapClient.AuditQuery{TapID: s.createdTapID})
+	if err != nil {
+		return fmt.Errorf("could not get audit log: %w", err)
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("audit log is empty")
+	}
+	if entries[len(entries)-1].Action != action {
+		return fmt.Errorf("expected %s, got %s", action, entries[len(entries)-1].Action)
+	}
+	return nil
+}
+
+func iSeekTheTapToTheStartOfTheBuffer(ctx context.Context) error {
+	s := getState(ctx)
+	return s.tapClient.Seek(ctx, s.createdTapID, "")
+}
+
+func theReceiverShouldReceiveThatEventTwice(ctx context.Context) error {
+	s := getState(ctx)
+	received := []any{}
+	lastID := ""
+	for len(received) < 2 {
+		evts := []any{}
+		ids, err := s.webhookClient.PollForEvents(ctx, lastID, 2, &evts)
+		if err != nil {
+			return fmt.Errorf("failed polling for webhook events: %w", err)
+		}
+		received = append(received, evts...)
+		lastID = ids[len(ids)-1]
+	}
+	diff := cmp.Diff(received, []any{"evt1", "evt1"})
+	if diff != "" {
+		return fmt.Errorf("diff:\n%s", diff)
+	}
+	return nil
+}
+
+func aTapNamed(ctx context.Context, name string) error {
+	s := getState(ctx)
+	_, err := s.tapClient.CreateTap(ctx, tapClient.CreateTapOptions{
+		Name:       name,
+		Code:       `function mapEvents(evts){return evts}`,
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+	return nil
+}
+
+func applyConfiguration(ctx context.Context, config string, dryRun bool) error {
+	s := getState(ctx)
+	desired, err := reconcile.ParseFile([]byte(strings.ReplaceAll(config, "WEBHOOK_URL", s.webhookURL)))
+	if err != nil {
+		return err
+	}
+	s.applyResult, err = s.tapClient.Apply(ctx, data.ApplyRequest{
+		Taps:   desired,
+		Prune:  true,
+		DryRun: dryRun,
+	})
+	if err != nil {
+		return fmt.Errorf("could not apply taps: %w", err)
+	}
+	return nil
+}
+
+func iApplyWithPruning(ctx context.Context, config *godog.DocString) error {
+	return applyConfiguration(ctx, config.Content, false)
+}
+
+func iDryRunApplyingWithPruning(ctx context.Context, config *godog.DocString) error {
+	return applyConfiguration(ctx, config.Content, true)
+}
+
+func theApplyResultShouldBe(ctx context.Context, expected string) error {
+	s := getState(ctx)
+	actions := []string{}
+	for _, a := range s.applyResult.Actions {
+		actions = append(actions, a.Action+" "+a.Name)
+	}
+	diff := cmp.Diff(strings.Split(expected, ", "), actions)
+	if diff != "" {
+		return fmt.Errorf("diff:\n%s", diff)
+	}
+	return nil
+}
+
+func theTapNamesShouldBe(ctx context.Context, expected string) error {
+	s := getState(ctx)
+	taps, err := s.tapClient.List(ctx)
+	if err != nil {
+		return fmt.Errorf("could not list taps: %w", err)
+	}
+	names := []string{}
+	for _, t := range taps {
+		names = append(names, t.Name)
+	}
+	sort.Strings(names)
+	diff := cmp.Diff(strings.Split(expected, ", "), names)
+	if diff != "" {
+		return fmt.Errorf("diff:\n%s", diff)
+	}
+	return nil
+}
+
+func iExportTheTapsOnceTheCursorAndStateAreStored(ctx context.Context) error {
+	s := getState(ctx)
+	for {
+		archive, err := s.tapClient.Export(ctx, "")
+		if err != nil {
+			return fmt.Errorf("could not export taps: %w", err)
+		}
+		if len(archive.Taps) == 1 && archive.Taps[0].LastID != "" && len(archive.Taps[0].State) > 0 {
+			s.archive = archive
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("cursor and state were not stored: %v", archive.Taps)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func iExportTheTaps(ctx context.Context) (err error) {
+	s := getState(ctx)
+	s.archive, err = s.tapClient.Export(ctx, "")
+	if err != nil {
+		return fmt.Errorf("could not export taps: %w", err)
+	}
+	return nil
+}
+
+func iImportTheArchiveIntoANewServer(ctx context.Context) error {
+	s := getState(ctx)
+	tapServerURL, err := testrig.StartServer(ctx, logr.FromContextOrDiscard(ctx), s.bufferURL)
+	if err != nil {
+		return fmt.Errorf("could not start tap server: %w", err)
+	}
+
+	s.newTapClient, err = tapClient.New(tapServerURL)
+	if err != nil {
+		return fmt.Errorf("could not create tap client: %w", err)
+	}
+
+	_, err = s.newTapClient.Import(ctx, s.archive, data.OnConflictFail)
+	if err != nil {
+		return fmt.Errorf("could not import archive: %w", err)
+	}
+	return nil
+}
+
+func theNewServerShouldHaveTheLibraryAtVersion(ctx context.Context, name, version string) error {
+	s := getState(ctx)
+	libs, err := s.newTapClient.ListLibraries(ctx)
+	if err != nil {
+		return fmt.Errorf("could not list libraries: %w", err)
+	}
+	diff := cmp.Diff(libs, []data.LibraryListEntry{{Name: name, Latest: version, Versions: []string{version}}})
+	if diff != "" {
+		return fmt.Errorf("diff:\n%s", diff)
+	}
+	return nil
+}
+
+func exportingTheNewServerShouldGiveTheSameTaps(ctx context.Context) error {
+	s := getState(ctx)
+	archive, err := s.newTapClient.Export(ctx, "")
+	if err != nil {
+		return fmt.Errorf("could not export taps: %w", err)
+	}
+	diff := cmp.Diff(s.archive.Taps, archive.Taps)
+	if diff != "" {
+		return fmt.Errorf("diff:\n%s", diff)
+	}
+	return nil
+}
+
+func iImportTheArchive(ctx context.Context) error {
+	s := getState(ctx)
+	s.importResult, s.requestErr = s.tapClient.Import(ctx, s.archive, data.OnConflictFail)
+	return nil
+}
+
+func theIdOfTheExportedTapIsChangedTo(ctx context.Context, id string) error {
+	s := getState(ctx)
+	if len(s.archive.Taps) != 1 {
+		return fmt.Errorf("expected one exported tap, got %d", len(s.archive.Taps))
+	}
+	s.archive.Taps[0].ID = id
+	return nil
+}
+
+func iImportTheArchiveSkippingConflicts(ctx context.Context) (err error) {
+	s := getState(ctx)
+	s.importResult, err = s.tapClient.Import(ctx, s.archive, data.OnConflictSkip)
+	if err != nil {
+		return fmt.Errorf("could not import archive: %w", err)
+	}
+	return nil
+}
+
+func theTapShouldBeSkipped(ctx context.Context) error {
+	s := getState(ctx)
+	diff := cmp.Diff(&data.ImportResult{
+		Created:     []string{},
+		Overwritten: []string{},
+		Skipped:     []string{data.DefaultNamespace + "/" + s.createdTapID},
+	}, s.importResult)
+	if diff != "" {
+		return fmt.Errorf("diff:\n%s", diff)
+	}
+	return nil
+}
+
+func iBackUpTheServer(ctx context.Context) error {
+	s := getState(ctx)
+	td, err := os.MkdirTemp("", "")
+	if err != nil {
+		return fmt.Errorf("could not create temp dir: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		os.RemoveAll(td)
+	}()
+	s.snapshotDir = td
+
+	f, err := os.Create(filepath.Join(td, "snapshot"))
+	if err != nil {
+		return fmt.Errorf("could not create snapshot file: %w", err)
+	}
+	defer f.Close()
+
+	err = s.tapClient.Backup(ctx, f)
+	if err != nil {
+		return fmt.Errorf("could not back up server: %w", err)
+	}
+	return nil
+}
+
+func theSnapshotShouldBeValid(ctx context.Context) error {
+	s := getState(ctx)
+	return server.ValidateSnapshot(filepath.Join(s.snapshotDir, "snapshot"))
+}
+
+func iRestoreTheSnapshotAndStartANewServerOnIt(ctx context.Context) error {
+	s := getState(ctx)
+	stateFile := filepath.Join(s.snapshotDir, "state")
+	err := server.RestoreSnapshot(filepath.Join(s.snapshotDir, "snapshot"), stateFile, false)
+	if err != nil {
+		return fmt.Errorf("could not restore snapshot: %w", err)
+	}
+
+	tapServerURL, err := testrig.StartServerOnState(ctx, logr.FromContextOrDiscard(ctx), s.bufferURL, stateFile)
+	if err != nil {
+		return fmt.Errorf("could not start tap server: %w", err)
+	}
+
+	s.newTapClient, err = tapClient.New(tapServerURL)
+	if err != nil {
+		return fmt.Errorf("could not create tap client: %w", err)
+	}
+	return nil
+}
+
+func theNewServerShouldHaveTheTap(ctx context.Context) error {
+	s := getState(ctx)
+	opts, err := s.newTapClient.GetTap(ctx, s.createdTapID)
+	if err != nil {
+		return fmt.Errorf("could not get tap: %w", err)
+	}
+	if opts.Name != "tap1" {
+		return fmt.Errorf("expected tap1, got %s", opts.Name)
+	}
+	return nil
+}
+
+func theSnapshotIsTruncated(ctx context.Context) error {
+	s := getState(ctx)
+	snapshot := filepath.Join(s.snapshotDir, "snapshot")
+	fi, err := os.Stat(snapshot)
+	if err != nil {
+		return err
+	}
+	return os.Truncate(snapshot, fi.Size()/2)
+}
+
+func iWriteScheduledBackupsKeeping(ctx context.Context, count, keep int) error {
+	s := getState(ctx)
+	td, err := os.MkdirTemp("", "")
+	if err != nil {
+		return fmt.Errorf("could not create temp dir: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		os.RemoveAll(td)
+	}()
+	s.snapshotDir = td
+
+	db, err := embedded.Open(filepath.Join(td, "db"), 0700, embedded.Options{})
+	if err != nil {
+		return fmt.Errorf("could not open db: %w", err)
+	}
+	defer db.Close()
+
+	backupDir := filepath.Join(td, "backups")
+	err = os.Mkdir(backupDir, 0700)
+	if err != nil {
+		return fmt.Errorf("could not create backup dir: %w", err)
+	}
+
+	// in quick succession, like a restart right after a scheduled backup
+	for i := 0; i < count; i++ {
+		_, err = server.WriteBackup(db, backupDir)
+		if err != nil {
+			return fmt.Errorf("could not write backup: %w", err)
+		}
+	}
+
+	return server.RotateBackups(backupDir, keep)
+}
+
+func theBackupDirectoryShouldHoldSnapshots(ctx context.Context, count int) error {
+	s := getState(ctx)
+	entries, err := os.ReadDir(filepath.Join(s.snapshotDir, "backups"))
+	if err != nil {
+		return fmt.Errorf("could not list backups: %w", err)
+	}
+	if len(entries) != count {
+		return fmt.Errorf("expected %d snapshots, found %d", count, len(entries))
+	}
+	return nil
+}
+
+func restoringTheSnapshotShouldFail(ctx context.Context) error {
+	s := getState(ctx)
+	stateFile := filepath.Join(s.snapshotDir, "state")
+	err := server.RestoreSnapshot(filepath.Join(s.snapshotDir, "snapshot"), stateFile, false)
+	if err == nil {
+		return errors.New("expected restoring to fail")
+	}
+	_, err = os.Stat(stateFile)
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("state file should not exist: %v", err)
+	}
+	return nil
+}
+
+func iCreateTheTapWithTheIdempotencyKey(ctx context.Context, name, key string) error {
+	s := getState(ctx)
+	id, err := s.tapClient.CreateTapIdempotently(ctx, key, tapClient.CreateTapOptions{
+		Name:       name,
+		Code:       `function mapEvents(evts){return evts.map(([id, evt]) => evt)}`,
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+	s.requestErr = err
+	if err == nil {
+		s.createdIDs = append(s.createdIDs, id)
+	}
+	return nil
+}
+
+func iCreateTheTapWithTheId(ctx context.Context, name, id string) error {
+	s := getState(ctx)
+	id, err := s.tapClient.CreateTapWithID(ctx, id, tapClient.CreateTapOptions{
+		Name:       name,
+		Code:       `function mapEvents(evts){return evts.map(([id, evt]) => evt)}`,
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+	})
+	s.requestErr = err
+	if err == nil {
+		s.createdIDs = append(s.createdIDs, id)
+	}
+	return nil
+}
+
+func bothCreatesShouldReturnTheSameTap(ctx context.Context) error {
+	s := getState(ctx)
+	if len(s.createdIDs) != 2 {
+		return fmt.Errorf("expected two successful creates, got %d", len(s.createdIDs))
+	}
+	if s.createdIDs[0] != s.createdIDs[1] {
+		return fmt.Errorf("expected the same tap, got %s and %s", s.createdIDs[0], s.createdIDs[1])
+	}
+	return nil
+}
+
+func iReadTheTapWithItsRevision(ctx context.Context) (err error) {
+	s := getState(ctx)
+	_, s.revision, err = s.tapClient.GetTapWithRevision(ctx, s.createdTapID)
+	if err != nil {
+		return fmt.Errorf("could not get tap: %w", err)
+	}
+	return nil
+}
+
+func iUpdateTheWebhookUrlOfTheTapAtThatRevision(ctx context.Context) error {
+	s := getState(ctx)
+	s.requestErr = s.tapClient.WithRevision(s.revision).UpdateTap(ctx, s.createdTapID, tapClient.CreateTapOptions{
+		Name:       "tap1",
+		Code:       `function mapEvents(evts){return evts.map(([id, evt]) => evt)}`,
+		WebhookURL: s.webhookURL + "?revision=true",
+		BatchLimit: 20,
+	})
+	return nil
+}
+
+func iDeleteTheTapAtThatRevision(ctx context.Context) error {
+	s := getState(ctx)
+	s.requestErr = s.tapClient.WithRevision(s.revision).Delete(ctx, s.createdTapID)
+	return nil
+}
+
+func theTapShouldBeAtRevision(ctx context.Context, expected int) error {
+	s := getState(ctx)
+	_, rev, err := s.tapClient.GetTapWithRevision(ctx, s.createdTapID)
+	if err != nil {
+		return fmt.Errorf("could not get tap: %w", err)
+	}
+	if rev != uint64(expected) {
+		return fmt.Errorf("expected revision %d, got %d", expected, rev)
+	}
+	return nil
+}
+
+func iRollTheTapBackToRevision(ctx context.Context, revision int) error {
+	s := getState(ctx)
+	s.requestErr = s.tapClient.Rollback(ctx, s.createdTapID, uint64(revision))
+	return nil
+}
+
+func theTapShouldHaveRevisions(ctx context.Context, expected int) error {
+	s := getState(ctx)
+	revisions, err := s.tapClient.Revisions(ctx, s.createdTapID)
+	if err != nil {
+		return fmt.Errorf("could not get revisions: %w", err)
+	}
+	if len(revisions) != expected {
+		return fmt.Errorf("expected %d revisions, got %d", expected, len(revisions))
+	}
+	for i, tr := range revisions {
+		if tr.Revision != uint64(i+1) {
+			return fmt.Errorf("expected revision %d, got %d", i+1, tr.Revision)
+		}
+	}
+	return nil
+}
+
+func revisionOfTheTapShouldHaveTheOptionsOfRevision(ctx context.Context, revision, of int) error {
+	s := getState(ctx)
+	revisions, err := s.tapClient.Revisions(ctx, s.createdTapID)
+	if err != nil {
+		return fmt.Errorf("could not get revisions: %w", err)
+	}
+	byRevision := map[uint64]data.TapOptions{}
+	for _, tr := range revisions {
+		byRevision[tr.Revision] = tr.Options
+	}
+	diff := cmp.Diff(byRevision[uint64(of)], byRevision[uint64(revision)])
+	if diff != "" {
+		return fmt.Errorf("diff:\n%s", diff)
+	}
+	current, err := s.tapClient.GetTap(ctx, s.createdTapID)
+	if err != nil {
+		return fmt.Errorf("could not get tap: %w", err)
+	}
+	diff = cmp.Diff(byRevision[uint64(revision)], *current)
+	if diff != "" {
+		return fmt.Errorf("current options differ from revision %d:\n%s", revision, diff)
+	}
+	return nil
+}
+
+func aTapNamedLabelled(ctx context.Context, name, labels string) error {
+	s := getState(ctx)
+	opts := tapClient.CreateTapOptions{
+		Name:       name,
+		Code:       `function mapEvents(evts){return evts}`,
+		WebhookURL: s.webhookURL,
+		BatchLimit: 20,
+		Labels:     map[string]string{},
+	}
+	for _, l := range strings.Split(labels, ",") {
+		k, v, _ := strings.Cut(l, "=")
+		opts.Labels[k] = v
+	}
+	_, err := s.tapClient.CreateTap(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("could not create tap: %w", err)
+	}
+	return nil
+}
+
+func theTapNamedIsPaused(ctx context.Context, name string) error {
+	s := getState(ctx)
+	taps, err := s.tapClient.ListFiltered(ctx, tapClient.ListFilter{NamePrefix: name})
+	if err != nil {
+		return fmt.Errorf("could not list taps: %w", err)
+	}
+	for _, t := range taps {
+		if t.Name == name {
+			return s.tapClient.PauseTap(ctx, t.ID)
+		}
+	}
+	return fmt.Errorf("tap %s not found", name)
+}
+
+func iListTheTapsSelecting(ctx context.Context, selector string) (err error) {
+	s := getState(ctx)
+	s.listResult, s.requestErr = s.tapClient.ListFiltered(ctx, tapClient.ListFilter{Selector: selector})
+	return nil
+}
+
+func iListTheTapsWithStatus(ctx context.Context, status string) (err error) {
+	s := getState(ctx)
+	s.listResult, s.requestErr = s.tapClient.ListFiltered(ctx, tapClient.ListFilter{Status: status})
+	return nil
+}
+
+func iListTheTapsWithNamesStartingWith(ctx context.Context, prefix string) (err error) {
+	s := getState(ctx)
+	s.listResult, s.requestErr = s.tapClient.ListFiltered(ctx, tapClient.ListFilter{NamePrefix: prefix})
+	return nil
+}
+
+func theListedTapsShouldBe(ctx context.Context, expected string) error {
+	s := getState(ctx)
+	if s.requestErr != nil {
+		return s.requestErr
+	}
+	names := []string{}
+	for _, t := range s.listResult {
+		names = append(names, t.Name)
+	}
+	sort.Strings(names)
+	want := []string{}
+	if expected != "" {
+		want = strings.Split(expected, ", ")
+	}
+	diff := cmp.Diff(want, names)
+	if diff != "" {
+		return fmt.Errorf("diff:\n%s", diff)
+	}
+	return nil
+}
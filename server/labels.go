@@ -0,0 +1,130 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/draganm/event-tap/data"
+	"github.com/draganm/event-tap/server/tap"
+)
+
+var labelKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9_./-]{0,61}[A-Za-z0-9])?$`)
+var labelValueRegexp = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9_.-]{0,61}[A-Za-z0-9])?)?$`)
+
+func validateLabels(labels map[string]string) error {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if !labelKeyRegexp.MatchString(k) {
+			return fmt.Errorf("invalid label key %q", k)
+		}
+		if !labelValueRegexp.MatchString(labels[k]) {
+			return fmt.Errorf("invalid value %q of label %s", labels[k], k)
+		}
+	}
+	return nil
+}
+
+type requirementOp int
+
+const (
+	opEquals requirementOp = iota
+	opNotEquals
+	opExists
+	opNotExists
+)
+
+type requirement struct {
+	key   string
+	op    requirementOp
+	value string
+}
+
+// labelSelector matches the labels of taps against all of its requirements.
+type labelSelector []requirement
+
+// parseSelector parses a comma separated list of requirements: key=value,
+// key==value, key!=value, key (the label is set) and !key (it is not).
+func parseSelector(s string) (labelSelector, error) {
+	sel := labelSelector{}
+	if strings.TrimSpace(s) == "" {
+		return sel, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		req := requirement{}
+		switch {
+		case strings.Contains(part, "!="):
+			kv := strings.SplitN(part, "!=", 2)
+			req = requirement{key: kv[0], op: opNotEquals, value: kv[1]}
+		case strings.Contains(part, "=="):
+			kv := strings.SplitN(part, "==", 2)
+			req = requirement{key: kv[0], op: opEquals, value: kv[1]}
+		case strings.Contains(part, "="):
+			kv := strings.SplitN(part, "=", 2)
+			req = requirement{key: kv[0], op: opEquals, value: kv[1]}
+		case strings.HasPrefix(part, "!"):
+			req = requirement{key: strings.TrimPrefix(part, "!"), op: opNotExists}
+		default:
+			req = requirement{key: part, op: opExists}
+		}
+
+		req.key = strings.TrimSpace(req.key)
+		req.value = strings.TrimSpace(req.value)
+
+		if !labelKeyRegexp.MatchString(req.key) {
+			return nil, fmt.Errorf("invalid label key %q in selector", req.key)
+		}
+		if !labelValueRegexp.MatchString(req.value) {
+			return nil, fmt.Errorf("invalid label value %q in selector", req.value)
+		}
+
+		sel = append(sel, req)
+	}
+
+	return sel, nil
+}
+
+func (sel labelSelector) matches(labels map[string]string) bool {
+	for _, req := range sel {
+		v, found := labels[req.key]
+		switch req.op {
+		case opEquals:
+			if !found || v != req.value {
+				return false
+			}
+		case opNotEquals:
+			if found && v == req.value {
+				return false
+			}
+		case opExists:
+			if !found {
+				return false
+			}
+		case opNotExists:
+			if found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// statusFilterOf sorts the status of a tap into the status filters of the list.
+func statusFilterOf(status string) string {
+	switch status {
+	case StatusPaused:
+		return data.StatusFilterPaused
+	case tap.StatusRunning:
+		return data.StatusFilterRunning
+	default:
+		return data.StatusFilterFailing
+	}
+}
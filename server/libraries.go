@@ -0,0 +1,165 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/dop251/goja"
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/draganm/event-tap/data"
+	"github.com/draganm/event-tap/server/tap"
+)
+
+var librariesPath = dbpath.ToPath("libraries")
+
+// libraries loads library modules required by tap code. Every library is
+// stored as `libraries/{name}/versions/{version}` with `libraries/{name}/latest`
+// pointing to the most recently published version.
+type libraries struct {
+	db bolted.Database
+}
+
+func (l *libraries) Load(name, version string) (string, string, error) {
+	var code string
+	err := bolted.SugaredRead(l.db, func(tx bolted.SugaredReadTx) error {
+		libPath := librariesPath.Append(name)
+		if !tx.Exists(libPath) {
+			return fmt.Errorf("library %s: %w", name, ErrNotFound)
+		}
+
+		if version == tap.LatestVersion {
+			version = string(tx.Get(libPath.Append("latest")))
+		}
+
+		versionPath := libPath.Append("versions", version)
+		if !tx.Exists(versionPath) {
+			return fmt.Errorf("library %s@%s: %w", name, version, ErrNotFound)
+		}
+
+		code = string(tx.Get(versionPath))
+		return nil
+	})
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return version, code, nil
+}
+
+// requireRegexp finds the modules code requires with a literal id.
+var requireRegexp = regexp.MustCompile(`require\(\s*["']([^"'\s]+)["']\s*\)`)
+
+// requiredLibraries returns the installed library versions the code requires,
+// along with the ones those libraries require in turn. Requires of the latest
+// version resolve to the version that is currently the latest.
+func requiredLibraries(tx bolted.SugaredReadTx, codes []string) []data.ArchivedLibrary {
+	byName := map[string]*data.ArchivedLibrary{}
+
+	for len(codes) > 0 {
+		code := codes[0]
+		codes = codes[1:]
+
+		for _, m := range requireRegexp.FindAllStringSubmatch(code, -1) {
+			name, version := tap.ParseModuleID(m[1])
+			libPath := librariesPath.Append(name)
+			if !tx.Exists(libPath) {
+				continue
+			}
+
+			latest := string(tx.Get(libPath.Append("latest")))
+			if version == tap.LatestVersion {
+				version = latest
+			}
+
+			versionPath := libPath.Append("versions", version)
+			if !tx.Exists(versionPath) {
+				continue
+			}
+
+			al, found := byName[name]
+			if !found {
+				al = &data.ArchivedLibrary{Name: name, Latest: latest, Versions: map[string]string{}}
+				byName[name] = al
+			}
+
+			if _, found := al.Versions[version]; found {
+				continue
+			}
+
+			libCode := string(tx.Get(versionPath))
+			al.Versions[version] = libCode
+			codes = append(codes, libCode)
+		}
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	libs := make([]data.ArchivedLibrary, len(names))
+	for i, name := range names {
+		libs[i] = *byName[name]
+	}
+	return libs
+}
+
+// validateArchivedLibrary checks a library of an archive like a published one.
+func validateArchivedLibrary(al data.ArchivedLibrary) error {
+	if name, _ := tap.ParseModuleID(al.Name); al.Name == "" || name != al.Name {
+		return fmt.Errorf("invalid library name %q", al.Name)
+	}
+
+	for version, code := range al.Versions {
+		if version == "" || version == tap.LatestVersion {
+			return fmt.Errorf("library %s needs versions other than latest", al.Name)
+		}
+		_, err := goja.Compile(al.Name+".js", code, true)
+		if err != nil {
+			return fmt.Errorf("could not parse library %s@%s: %w", al.Name, version, err)
+		}
+	}
+
+	return nil
+}
+
+// installLibraries stores the library versions of an archive that are missing.
+// Installed versions must have the same code. The latest version is only set
+// for libraries that were not installed before. Only admins may install
+// libraries, as they are shared by all namespaces.
+func installLibraries(tx bolted.SugaredWriteTx, libs []data.ArchivedLibrary, mayInstall bool) error {
+	for _, al := range libs {
+		libPath := librariesPath.Append(al.Name)
+		isNew := !tx.Exists(libPath)
+
+		for version, code := range al.Versions {
+			versionPath := libPath.Append("versions", version)
+			if !isNew && tx.Exists(versionPath) {
+				if string(tx.Get(versionPath)) != code {
+					return fmt.Errorf("%w: library %s@%s differs from the installed one", ErrConflict, al.Name, version)
+				}
+				continue
+			}
+
+			if !mayInstall {
+				return fmt.Errorf("%w: installing library %s@%s needs the admin scope", ErrForbidden, al.Name, version)
+			}
+
+			if !tx.Exists(libPath) {
+				tx.CreateMap(libPath)
+				tx.CreateMap(libPath.Append("versions"))
+			}
+			tx.Put(versionPath, []byte(code))
+		}
+
+		if _, found := al.Versions[al.Latest]; isNew && found {
+			tx.Put(libPath.Append("latest"), []byte(al.Latest))
+		}
+	}
+
+	return nil
+}
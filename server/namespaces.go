@@ -0,0 +1,240 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/draganm/event-tap/data"
+	"github.com/draganm/event-tap/server/tap"
+	"github.com/gorilla/mux"
+)
+
+var namespacesPath = dbpath.ToPath("namespaces")
+
+// legacyTapsPath held all taps before namespaces were introduced.
+var legacyTapsPath = dbpath.ToPath("taps")
+
+var namespaceNameRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+func validNamespace(ns string) bool {
+	return namespaceNameRegexp.MatchString(ns)
+}
+
+func tapsPathOf(ns string) dbpath.Path {
+	return namespacesPath.Append(ns, "taps")
+}
+
+func quotaPathOf(ns string) dbpath.Path {
+	return namespacesPath.Append(ns, "quota")
+}
+
+// tapRef identifies a tap across namespaces.
+type tapRef struct {
+	namespace string
+	id        string
+}
+
+func (tr tapRef) path() dbpath.Path {
+	return tapsPathOf(tr.namespace).Append(tr.id)
+}
+
+// namespaceOf returns the namespace of the request, routes without a namespace use the default one.
+func namespaceOf(r *http.Request) string {
+	ns := mux.Vars(r)["namespace"]
+	if ns == "" {
+		return data.DefaultNamespace
+	}
+	return ns
+}
+
+func ensureNamespace(tx bolted.SugaredWriteTx, ns string) {
+	if !tx.Exists(namespacesPath.Append(ns)) {
+		tx.CreateMap(namespacesPath.Append(ns))
+		tx.CreateMap(tapsPathOf(ns))
+	}
+}
+
+func readQuota(tx bolted.SugaredReadTx, ns string) (data.NamespaceQuota, error) {
+	q := data.NamespaceQuota{}
+	if !tx.Exists(quotaPathOf(ns)) {
+		return q, nil
+	}
+	err := json.Unmarshal(tx.Get(quotaPathOf(ns)), &q)
+	if err != nil {
+		return q, fmt.Errorf("could not parse quota of %s: %w", ns, err)
+	}
+	return q, nil
+}
+
+// migrateLegacyTaps moves taps created before namespaces existed into the default namespace.
+func migrateLegacyTaps(tx bolted.SugaredWriteTx) {
+	if !tx.Exists(legacyTapsPath) {
+		return
+	}
+	ensureNamespace(tx, data.DefaultNamespace)
+	for it := tx.Iterator(legacyTapsPath); !it.IsDone(); it.Next() {
+		copyTree(tx, legacyTapsPath.Append(it.GetKey()), tapsPathOf(data.DefaultNamespace).Append(it.GetKey()))
+	}
+	tx.Delete(legacyTapsPath)
+}
+
+func copyTree(tx bolted.SugaredWriteTx, from, to dbpath.Path) {
+	tx.CreateMap(to)
+	for it := tx.Iterator(from); !it.IsDone(); it.Next() {
+		if tx.IsMap(from.Append(it.GetKey())) {
+			copyTree(tx, from.Append(it.GetKey()), to.Append(it.GetKey()))
+			continue
+		}
+		tx.Put(to.Append(it.GetKey()), it.GetValue())
+	}
+}
+
+// inNamespace only passes requests for valid namespaces the token of the request is bound to.
+func (s *Server) inNamespace(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ns := namespaceOf(r)
+		if !validNamespace(ns) {
+			http.Error(w, fmt.Sprintf("invalid namespace %q", ns), http.StatusBadRequest)
+			return
+		}
+
+		if !principalFromContext(r.Context()).allows(ns) {
+			http.Error(w, fmt.Sprintf("token is not bound to namespace %s", ns), http.StatusForbidden)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// sharedLimiter returns the limiter of the namespace quota, creating it on first use.
+func (s *Server) sharedLimiter(ns string) (*tap.SharedLimiter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, found := s.limiters[ns]
+	if found {
+		return l, nil
+	}
+
+	var q data.NamespaceQuota
+	err := bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) (err error) {
+		q, err = readQuota(tx, ns)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	l = tap.NewSharedLimiter(q.MaxRequestsPerSecond)
+	s.limiters[ns] = l
+	return l, nil
+}
+
+func (s *Server) listNamespaces(w http.ResponseWriter, r *http.Request) {
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path)
+
+	p := principalFromContext(r.Context())
+
+	entries := []data.NamespaceListEntry{}
+	err := bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) error {
+		for it := tx.Iterator(namespacesPath); !it.IsDone(); it.Next() {
+			ns := it.GetKey()
+			if !p.allows(ns) {
+				continue
+			}
+			q, err := readQuota(tx, ns)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, data.NamespaceListEntry{
+				Name:  ns,
+				Taps:  int(tx.Size(tapsPathOf(ns))),
+				Quota: q,
+			})
+		}
+		return nil
+	})
+
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not list namespaces: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not list namespaces")
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+func (s *Server) getQuota(w http.ResponseWriter, r *http.Request) {
+	ns := namespaceOf(r)
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path, "namespace", ns)
+
+	var q data.NamespaceQuota
+	err := bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) (err error) {
+		q, err = readQuota(tx, ns)
+		return err
+	})
+
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not read quota: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not read quota")
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(q)
+}
+
+func (s *Server) setQuota(w http.ResponseWriter, r *http.Request) {
+	ns := namespaceOf(r)
+	log := s.log.WithValues("method", r.Method, "path", r.URL.Path, "namespace", ns)
+
+	q := data.NamespaceQuota{}
+	err := json.NewDecoder(r.Body).Decode(&q)
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not decode quota: %w", err).Error(), http.StatusBadRequest)
+		log.Error(err, "could not decode quota")
+		return
+	}
+
+	if q.MaxTaps < 0 || q.MaxRequestsPerSecond < 0 {
+		http.Error(w, "quotas must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	d, err := json.Marshal(q)
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not marshal quota: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not marshal quota")
+		return
+	}
+
+	err = bolted.SugaredWrite(s.db, func(tx bolted.SugaredWriteTx) error {
+		ensureNamespace(tx, ns)
+		tx.Put(quotaPathOf(ns), d)
+		return nil
+	})
+
+	if err != nil {
+		http.Error(w, fmt.Errorf("could not store quota: %w", err).Error(), http.StatusInternalServerError)
+		log.Error(err, "could not store quota")
+		return
+	}
+
+	s.mu.Lock()
+	l, found := s.limiters[ns]
+	if found {
+		l.Set(q.MaxRequestsPerSecond)
+	}
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/draganm/event-tap/data"
+)
+
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// readRevision returns the revision of the options stored at the tap path.
+// Taps created before revisions were introduced are at revision 1.
+func readRevision(tx bolted.SugaredReadTx, tapPath dbpath.Path) uint64 {
+	rp := tapPath.Append("revision")
+	if !tx.Exists(rp) {
+		return 1
+	}
+	rev, err := strconv.ParseUint(string(tx.Get(rp)), 10, 64)
+	if err != nil {
+		return 1
+	}
+	return rev
+}
+
+func writeRevision(tx bolted.SugaredWriteTx, tapPath dbpath.Path, rev uint64) {
+	tx.Put(tapPath.Append("revision"), []byte(strconv.FormatUint(rev, 10)))
+}
+
+func revisionKey(rev uint64) string {
+	return fmt.Sprintf("%020d", rev)
+}
+
+// nextRevision moves the tap to the next revision and keeps the options of
+// it in the history of the tap. It has to be called before the options are
+// stored, so the current options of taps without a history are kept too.
+func nextRevision(tx bolted.SugaredWriteTx, tapPath dbpath.Path, opts data.TapOptions) error {
+	historyPath := tapPath.Append("revisions")
+	if !tx.Exists(historyPath) {
+		tx.CreateMap(historyPath)
+	}
+
+	rev := uint64(1)
+	if tx.Exists(tapPath.Append("options")) {
+		current := readRevision(tx, tapPath)
+		if !tx.Exists(historyPath.Append(revisionKey(current))) {
+			old := data.TapOptions{}
+			err := json.Unmarshal(tx.Get(tapPath.Append("options")), &old)
+			if err != nil {
+				return fmt.Errorf("could not parse options: %w", err)
+			}
+			err = putRevision(tx, historyPath, data.TapRevision{Revision: current, Options: old})
+			if err != nil {
+				return err
+			}
+		}
+		rev = current + 1
+	}
+
+	err := putRevision(tx, historyPath, data.TapRevision{Revision: rev, CreatedAt: time.Now().UTC(), Options: opts})
+	if err != nil {
+		return err
+	}
+
+	writeRevision(tx, tapPath, rev)
+	return nil
+}
+
+func putRevision(tx bolted.SugaredWriteTx, historyPath dbpath.Path, tr data.TapRevision) error {
+	d, err := json.Marshal(tr)
+	if err != nil {
+		return fmt.Errorf("could not marshal revision: %w", err)
+	}
+	tx.Put(historyPath.Append(revisionKey(tr.Revision)), d)
+	return nil
+}
+
+// readRevisions returns the history of a tap, oldest first.
+func readRevisions(tx bolted.SugaredReadTx, ref tapRef) ([]data.TapRevision, error) {
+	if !tx.Exists(ref.path().Append("options")) {
+		return nil, ErrNotFound
+	}
+
+	revisions := []data.TapRevision{}
+	historyPath := ref.path().Append("revisions")
+	if !tx.Exists(historyPath) {
+		// the tap was not changed since the history is kept
+		opts, err := readTapOptions(tx, ref)
+		if err != nil {
+			return nil, err
+		}
+		return append(revisions, data.TapRevision{Revision: readRevision(tx, ref.path()), Options: opts}), nil
+	}
+
+	for it := tx.Iterator(historyPath); !it.IsDone(); it.Next() {
+		tr := data.TapRevision{}
+		err := json.Unmarshal(it.GetValue(), &tr)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse revision %s: %w", it.GetKey(), err)
+		}
+		revisions = append(revisions, tr)
+	}
+
+	return revisions, nil
+}
+
+func etagOf(rev uint64) string {
+	return strconv.Quote(strconv.FormatUint(rev, 10))
+}
+
+// checkIfMatch fails with ErrPreconditionFailed when the If-Match header of
+// the request names neither the current revision of the tap nor "*".
+func checkIfMatch(tx bolted.SugaredReadTx, r *http.Request, ref tapRef) error {
+	ifMatch := r.Header.Get("if-match")
+	if ifMatch == "" {
+		return nil
+	}
+
+	rev := readRevision(tx, ref.path())
+	current := etagOf(rev)
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		// weak tags never match, If-Match uses the strong comparison
+		if tag == "*" || tag == current {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: tap %s is at revision %d", ErrPreconditionFailed, ref.id, rev)
+}
+
+// preconditionHolds checks If-Match before a handler stops the tap, it writes
+// the error response when the precondition fails or the tap does not exist.
+func (s *Server) preconditionHolds(w http.ResponseWriter, r *http.Request, ref tapRef) bool {
+	if r.Header.Get("if-match") == "" {
+		return true
+	}
+
+	err := bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) error {
+		if !tx.Exists(ref.path()) {
+			return ErrNotFound
+		}
+		return checkIfMatch(tx, r, ref)
+	})
+
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+		return false
+	case errors.Is(err, ErrPreconditionFailed):
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return false
+	case err != nil:
+		http.Error(w, fmt.Errorf("could not read revision: %w", err).Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	return true
+}
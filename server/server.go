@@ -2,13 +2,16 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/draganm/bolted"
-	"github.com/draganm/bolted/dbpath"
 	"github.com/draganm/event-buffer/client"
+	"github.com/draganm/event-tap/data"
+	"github.com/draganm/event-tap/server/tap"
 	"github.com/go-logr/logr"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
@@ -19,14 +22,50 @@ type Server struct {
 	log logr.Logger
 	http.Handler
 
-	bufferClient *client.Client
-	mu           *sync.Mutex
-	tapCancels   map[string]context.CancelFunc
+	bufferClient  *client.Client
+	breakers      *tap.CircuitBreakers
+	mu            *sync.Mutex
+	taps          map[tapRef]*runningTap
+	lifecycles    map[tapRef]*sync.Mutex
+	logBuffers    map[tapRef]*tap.LogBuffer
+	limiters      map[string]*tap.SharedLimiter
+	adminToken    string
+	lookupHosts   []string
+	dryRunTimeout time.Duration
 }
 
-var tapsPath = dbpath.ToPath("taps")
+// Config holds the optional settings of a server.
+type Config struct {
+	// AdminToken is a token with the admin scope that is not stored in the
+	// db. Setting it requires all requests to be authenticated, without it
+	// authentication is disabled and no tokens can be created.
+	AdminToken string
+
+	// CircuitBreakerFailures is the number of consecutive failed webhook
+	// requests that open the circuit breaker of a host, 5 when not set.
+	CircuitBreakerFailures int
+
+	// CircuitBreakerCoolDown is how long an open circuit breaker rejects
+	// requests before it lets a probe through, 30s when not set.
+	CircuitBreakerCoolDown time.Duration
+
+	// LookupAllowedHosts are the hosts http lookups of taps may reach,
+	// entries starting with `*.` match any subdomain. The allow lists of
+	// taps can only narrow them down, without it taps can't make lookups.
+	LookupAllowedHosts []string
+
+	// DryRunTimeout is how long testing tap code may take at most, the
+	// execution_timeout of the tested options can only shorten it. 10s when
+	// not set.
+	DryRunTimeout time.Duration
+}
+
+type runningTap struct {
+	cancel context.CancelFunc
+	tap    *tap.Tap
+}
 
-func New(log logr.Logger, db bolted.Database, bufferBaseURL string) (*Server, error) {
+func New(log logr.Logger, db bolted.Database, bufferBaseURL string, cfg Config) (*Server, error) {
 
 	bufferClient, err := client.New(bufferBaseURL)
 	if err != nil {
@@ -34,9 +73,22 @@ func New(log logr.Logger, db bolted.Database, bufferBaseURL string) (*Server, er
 	}
 
 	err = bolted.SugaredWrite(db, func(tx bolted.SugaredWriteTx) error {
-		if !tx.Exists(tapsPath) {
-			tx.CreateMap(tapsPath)
-
+		if !tx.Exists(namespacesPath) {
+			tx.CreateMap(namespacesPath)
+		}
+		ensureNamespace(tx, data.DefaultNamespace)
+		migrateLegacyTaps(tx)
+		if !tx.Exists(librariesPath) {
+			tx.CreateMap(librariesPath)
+		}
+		if !tx.Exists(tokensPath) {
+			tx.CreateMap(tokensPath)
+		}
+		if cfg.AdminToken == "" && tx.Size(tokensPath) > 0 {
+			return errors.New("tokens are stored but no admin token is configured, they would not be checked")
+		}
+		if !tx.Exists(auditPath) {
+			tx.CreateMap(auditPath)
 		}
 		return nil
 	})
@@ -45,17 +97,36 @@ func New(log logr.Logger, db bolted.Database, bufferBaseURL string) (*Server, er
 		return nil, fmt.Errorf("could not initialize db: %w", err)
 	}
 
+	if cfg.CircuitBreakerFailures <= 0 {
+		cfg.CircuitBreakerFailures = 5
+	}
+
+	if cfg.CircuitBreakerCoolDown <= 0 {
+		cfg.CircuitBreakerCoolDown = 30 * time.Second
+	}
+
+	if cfg.DryRunTimeout <= 0 {
+		cfg.DryRunTimeout = 10 * time.Second
+	}
+
 	r := mux.NewRouter()
 
 	prometheus.Register(newStatsCollector(db, log))
 
 	s := &Server{
-		Handler:      r,
-		db:           db,
-		log:          log,
-		bufferClient: bufferClient,
-		mu:           &sync.Mutex{},
-		tapCancels:   map[string]context.CancelFunc{},
+		Handler:       r,
+		db:            db,
+		log:           log,
+		bufferClient:  bufferClient,
+		breakers:      tap.NewCircuitBreakers(cfg.CircuitBreakerFailures, cfg.CircuitBreakerCoolDown),
+		mu:            &sync.Mutex{},
+		taps:          map[tapRef]*runningTap{},
+		lifecycles:    map[tapRef]*sync.Mutex{},
+		logBuffers:    map[tapRef]*tap.LogBuffer{},
+		limiters:      map[string]*tap.SharedLimiter{},
+		adminToken:    cfg.AdminToken,
+		lookupHosts:   cfg.LookupAllowedHosts,
+		dryRunTimeout: cfg.DryRunTimeout,
 	}
 
 	err = s.startTaps(context.Background())
@@ -63,9 +134,36 @@ func New(log logr.Logger, db bolted.Database, bufferBaseURL string) (*Server, er
 		return nil, fmt.Errorf("could not start webhooks: %w", err)
 	}
 
-	r.Methods("POST").Path("/taps").HandlerFunc(s.create)
-	r.Methods("GET").Path("/taps").HandlerFunc(s.list)
-	r.Methods("DELETE").Path("/taps/{tapID}").HandlerFunc(s.delete)
+	// routes without a namespace manage the taps of the default namespace
+	for _, prefix := range []string{"", "/namespaces/{namespace}"} {
+		r.Methods("POST").Path(prefix + "/taps").HandlerFunc(s.requireScope(data.ScopeWrite, s.inNamespace(s.create)))
+		r.Methods("GET").Path(prefix + "/taps").HandlerFunc(s.requireScope(data.ScopeRead, s.inNamespace(s.list)))
+		r.Methods("POST").Path(prefix + "/taps/test").HandlerFunc(s.requireScope(data.ScopeWrite, s.inNamespace(s.dryRun)))
+		r.Methods("GET").Path(prefix + "/taps/{tapID}").HandlerFunc(s.requireScope(data.ScopeRead, s.inNamespace(s.get)))
+		r.Methods("PUT").Path(prefix + "/taps/{tapID}").HandlerFunc(s.requireScope(data.ScopeWrite, s.inNamespace(s.update)))
+		r.Methods("DELETE").Path(prefix + "/taps/{tapID}").HandlerFunc(s.requireScope(data.ScopeWrite, s.inNamespace(s.delete)))
+		r.Methods("POST").Path(prefix + "/taps/{tapID}/pause").HandlerFunc(s.requireScope(data.ScopeWrite, s.inNamespace(s.pause)))
+		r.Methods("POST").Path(prefix + "/taps/{tapID}/resume").HandlerFunc(s.requireScope(data.ScopeWrite, s.inNamespace(s.resume)))
+		r.Methods("PUT").Path(prefix + "/taps/{tapID}/cursor").HandlerFunc(s.requireScope(data.ScopeWrite, s.inNamespace(s.seek)))
+		r.Methods("PUT").Path(prefix + "/taps/{tapID}/rate_limit").HandlerFunc(s.requireScope(data.ScopeWrite, s.inNamespace(s.setRateLimit)))
+		r.Methods("GET").Path(prefix + "/taps/{tapID}/logs").HandlerFunc(s.requireScope(data.ScopeRead, s.inNamespace(s.logs)))
+		r.Methods("GET").Path(prefix + "/taps/{tapID}/revisions").HandlerFunc(s.requireScope(data.ScopeRead, s.inNamespace(s.revisions)))
+		r.Methods("POST").Path(prefix + "/taps/{tapID}/rollback").HandlerFunc(s.requireScope(data.ScopeWrite, s.inNamespace(s.rollback)))
+		r.Methods("POST").Path(prefix + "/apply").HandlerFunc(s.requireScope(data.ScopeWrite, s.inNamespace(s.apply)))
+	}
+	r.Methods("GET").Path("/backup").HandlerFunc(s.requireScope(data.ScopeAdmin, s.backup))
+	r.Methods("GET").Path("/export").HandlerFunc(s.requireScope(data.ScopeRead, s.export))
+	r.Methods("POST").Path("/import").HandlerFunc(s.requireScope(data.ScopeWrite, s.importArchive))
+	r.Methods("GET").Path("/audit").HandlerFunc(s.requireScope(data.ScopeRead, s.audit))
+	r.Methods("GET").Path("/namespaces").HandlerFunc(s.requireScope(data.ScopeRead, s.listNamespaces))
+	r.Methods("GET").Path("/namespaces/{namespace}/quota").HandlerFunc(s.requireScope(data.ScopeRead, s.inNamespace(s.getQuota)))
+	r.Methods("PUT").Path("/namespaces/{namespace}/quota").HandlerFunc(s.requireScope(data.ScopeAdmin, s.inNamespace(s.setQuota)))
+	// libraries are shared by all namespaces, publishing one restarts the taps using it
+	r.Methods("POST").Path("/libraries").HandlerFunc(s.requireScope(data.ScopeAdmin, s.publishLibrary))
+	r.Methods("GET").Path("/libraries").HandlerFunc(s.requireScope(data.ScopeRead, s.listLibraries))
+	r.Methods("POST").Path("/tokens").HandlerFunc(s.requireScope(data.ScopeAdmin, s.createToken))
+	r.Methods("GET").Path("/tokens").HandlerFunc(s.requireScope(data.ScopeAdmin, s.listTokens))
+	r.Methods("DELETE").Path("/tokens/{tokenID}").HandlerFunc(s.requireScope(data.ScopeAdmin, s.revokeToken))
 
 	return s, nil
 }
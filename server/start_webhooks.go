@@ -3,31 +3,169 @@ package server
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/draganm/bolted"
 	"github.com/draganm/event-tap/server/tap"
+	"github.com/go-logr/logr"
 )
 
 func (s *Server) startTaps(ctx context.Context) error {
-	return bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) (err error) {
-		for it := tx.Iterator(tapsPath); !it.IsDone(); it.Next() {
-			ctx, cancel := context.WithCancel(context.Background())
-			defer func() {
-				if err != nil {
-					cancel()
+	refs := []tapRef{}
+	err := bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) error {
+		for nsIt := tx.Iterator(namespacesPath); !nsIt.IsDone(); nsIt.Next() {
+			ns := nsIt.GetKey()
+			for it := tx.Iterator(tapsPathOf(ns)); !it.IsDone(); it.Next() {
+				ref := tapRef{namespace: ns, id: it.GetKey()}
+				if isPaused(tx, ref) {
+					continue
 				}
-			}()
-
-			err := tap.Start(ctx, s.log, s.db, tapsPath.Append(it.GetKey()), s.bufferClient)
-			if err != nil {
-				return fmt.Errorf("could not start tap for %s: %w", it.GetKey(), err)
+				refs = append(refs, ref)
 			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not list taps: %w", err)
+	}
 
-			s.mu.Lock()
-			s.tapCancels[it.GetKey()] = cancel
-			s.mu.Unlock()
-
+	for _, ref := range refs {
+		err = s.startTap(s.log, ref)
+		if err != nil {
+			return fmt.Errorf("could not start tap for %s in %s: %w", ref.id, ref.namespace, err)
 		}
+	}
+
+	return nil
+}
+
+func (s *Server) tapServices(ns string) (tap.Services, error) {
+	sl, err := s.sharedLimiter(ns)
+	if err != nil {
+		return tap.Services{}, fmt.Errorf("could not load quota of %s: %w", ns, err)
+	}
+	return tap.Services{
+		BufferClient:  s.bufferClient,
+		Breakers:      s.breakers,
+		Libraries:     &libraries{db: s.db},
+		SharedLimiter: sl,
+		LookupHosts:   s.lookupHosts,
+	}, nil
+}
+
+// lifecycleOf returns the lock that serializes starting and stopping the tap.
+func (s *Server) lifecycleOf(ref tapRef) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, found := s.lifecycles[ref]
+	if !found {
+		l = &sync.Mutex{}
+		s.lifecycles[ref] = l
+	}
+	return l
+}
+
+// logsOf returns the console output of the tap, kept across its restarts so
+// readers following it see increasing sequence numbers.
+func (s *Server) logsOf(ref tapRef) *tap.LogBuffer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lb, found := s.logBuffers[ref]
+	if !found {
+		lb = tap.NewLogBuffer()
+		s.logBuffers[ref] = lb
+	}
+	return lb
+}
+
+// forgetTap stops a deleted tap and drops its console output.
+func (s *Server) forgetTap(ref tapRef) {
+	s.stopTap(ref)
+
+	s.mu.Lock()
+	delete(s.logBuffers, ref)
+	s.mu.Unlock()
+}
+
+// startTap starts the tap, a running instance of it is stopped first. Paused
+// taps stay stopped, even when they were paused after the caller decided to
+// start them.
+func (s *Server) startTap(log logr.Logger, ref tapRef) error {
+	lc := s.lifecycleOf(ref)
+	lc.Lock()
+	defer lc.Unlock()
+
+	// a concurrent change may have started the tap since it was stopped
+	s.stopRunning(ref)
+
+	paused := false
+	err := bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) error {
+		paused = isPaused(tx, ref)
 		return nil
 	})
+	if err != nil {
+		return fmt.Errorf("could not read tap: %w", err)
+	}
+
+	if paused {
+		return nil
+	}
+
+	services, err := s.tapServices(ref.namespace)
+	if err != nil {
+		return err
+	}
+	services.Logs = s.logsOf(ref)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	t, err := tap.Start(ctx, log, s.db, ref.path(), services)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	s.mu.Lock()
+	s.taps[ref] = &runningTap{cancel: cancel, tap: t}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// stopTap stops a running tap and waits until it can't write to the database anymore.
+func (s *Server) stopTap(ref tapRef) {
+	lc := s.lifecycleOf(ref)
+	lc.Lock()
+	defer lc.Unlock()
+
+	s.stopRunning(ref)
+}
+
+// stopRunning stops the tap, the caller has to hold its lifecycle lock.
+func (s *Server) stopRunning(ref tapRef) {
+	s.mu.Lock()
+	rt, found := s.taps[ref]
+	if found {
+		rt.cancel()
+		delete(s.taps, ref)
+	}
+	s.mu.Unlock()
+
+	if found {
+		<-rt.tap.Done()
+	}
+}
+
+func (s *Server) restartTap(log logr.Logger, ref tapRef) error {
+	return s.startTap(log, ref)
+}
+
+// restartUnlessPaused starts a stopped tap again, unless it is paused.
+func (s *Server) restartUnlessPaused(log logr.Logger, ref tapRef) {
+	err := s.restartTap(log, ref)
+	if err != nil {
+		log.Error(err, "could not restart tap")
+	}
 }
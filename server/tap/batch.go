@@ -0,0 +1,119 @@
+package tap
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// batchWindow decides when the mapped output collected over one or more polls
+// is delivered. Without any thresholds every poll is delivered on its own.
+type batchWindow struct {
+	minSize  int
+	maxBytes int
+	maxWait  time.Duration
+}
+
+func newBatchWindow(opts options) (batchWindow, error) {
+	bw := batchWindow{
+		minSize:  opts.MinBatchSize,
+		maxBytes: opts.MaxBatchBytes,
+	}
+
+	if bw.minSize < 0 {
+		return bw, fmt.Errorf("min_batch_size must not be negative")
+	}
+
+	if bw.maxBytes < 0 {
+		return bw, fmt.Errorf("max_batch_bytes must not be negative")
+	}
+
+	if opts.MaxBatchWait != "" {
+		d, err := time.ParseDuration(opts.MaxBatchWait)
+		if err != nil {
+			return bw, fmt.Errorf("could not parse max_batch_wait: %w", err)
+		}
+		if d < 0 {
+			return bw, fmt.Errorf("max_batch_wait must not be negative")
+		}
+		bw.maxWait = d
+	}
+
+	return bw, nil
+}
+
+func (bw batchWindow) enabled() bool {
+	return bw.minSize > 0 || bw.maxBytes > 0 || bw.maxWait > 0
+}
+
+// deadline returns the time at which the batch has to be delivered regardless
+// of its size.
+func (bw batchWindow) deadline(b *batch) (time.Time, bool) {
+	if bw.maxWait == 0 || len(b.output) == 0 {
+		return time.Time{}, false
+	}
+	return b.started.Add(bw.maxWait), true
+}
+
+func (bw batchWindow) ready(b *batch, now time.Time) bool {
+	if !bw.enabled() {
+		return true
+	}
+
+	if len(b.output) == 0 {
+		// nothing to deliver, the cursor can move right away
+		return true
+	}
+
+	if bw.minSize > 0 && len(b.output) >= bw.minSize {
+		return true
+	}
+
+	if bw.maxBytes > 0 && b.bytes >= bw.maxBytes {
+		return true
+	}
+
+	if dl, ok := bw.deadline(b); ok && !now.Before(dl) {
+		return true
+	}
+
+	return false
+}
+
+// batch is the mapped output collected since the cursor was last stored.
+type batch struct {
+	output  []any
+	bytes   int
+	lastID  string
+	started time.Time
+}
+
+func (b *batch) add(ids []string, output []any, now time.Time) error {
+	if len(ids) > 0 {
+		b.lastID = ids[len(ids)-1]
+	}
+
+	if len(output) == 0 {
+		return nil
+	}
+
+	if len(b.output) == 0 {
+		b.started = now
+	}
+
+	for _, o := range output {
+		d, err := json.Marshal(o)
+		if err != nil {
+			return fmt.Errorf("could not marshal output: %w", err)
+		}
+		b.bytes += len(d)
+	}
+
+	b.output = append(b.output, output...)
+
+	return nil
+}
+
+func (b *batch) reset() {
+	*b = batch{}
+}
@@ -0,0 +1,74 @@
+package tap
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// celTransform maps a batch with a CEL expression. The expression gets the
+// array of `[id, event]` pairs as `events` and has to evaluate to a list,
+// e.g. `events.map(e, e[1])`.
+type celTransform struct {
+	stateless
+	prg     cel.Program
+	timeout time.Duration
+}
+
+func newCELTransform(opts options) (*celTransform, error) {
+	env, err := cel.NewEnv(cel.Variable("events", cel.ListType(cel.DynType)))
+	if err != nil {
+		return nil, fmt.Errorf("could not create cel environment: %w", err)
+	}
+
+	ast, iss := env.Compile(opts.Code)
+	if iss.Err() != nil {
+		return nil, fmt.Errorf("could not compile cel expression: %w", iss.Err())
+	}
+
+	prg, err := env.Program(ast, cel.InterruptCheckFrequency(100))
+	if err != nil {
+		return nil, fmt.Errorf("could not create cel program: %w", err)
+	}
+
+	timeout, err := parseExecutionTimeout(opts.ExecutionTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &celTransform{prg: prg, timeout: timeout}, nil
+}
+
+func (t *celTransform) mapBatch(ctx context.Context, events [][]any) ([]any, error) {
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	input := make([]any, len(events))
+	for i, ev := range events {
+		input[i] = ev
+	}
+
+	val, _, err := t.prg.ContextEval(ctx, map[string]any{"events": input})
+	if err != nil {
+		return nil, fmt.Errorf("cel expression failed: %w", err)
+	}
+
+	native, err := val.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+	if err != nil {
+		return nil, fmt.Errorf("could not convert cel result: %w", err)
+	}
+
+	result, isList := native.(*structpb.Value).AsInterface().([]any)
+	if !isList {
+		return nil, fmt.Errorf("cel expression has to evaluate to a list, got %s", val.Type().TypeName())
+	}
+
+	return result, nil
+}
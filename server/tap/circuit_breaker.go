@@ -0,0 +1,152 @@
+package tap
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitHalfOpen
+	circuitOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitClosed:
+		return "closed"
+	case circuitHalfOpen:
+		return "half-open"
+	default:
+		return "open"
+	}
+}
+
+// CircuitBreakers holds one circuit breaker per webhook host, shared by all
+// taps delivering to that host.
+type CircuitBreakers struct {
+	failureThreshold int
+	coolDown         time.Duration
+
+	mu     *sync.Mutex
+	byHost map[string]*circuitBreaker
+}
+
+// NewCircuitBreakers creates breakers that open after failureThreshold
+// consecutive failures and let a single probe through after coolDown.
+func NewCircuitBreakers(failureThreshold int, coolDown time.Duration) *CircuitBreakers {
+	return &CircuitBreakers{
+		failureThreshold: failureThreshold,
+		coolDown:         coolDown,
+		mu:               &sync.Mutex{},
+		byHost:           map[string]*circuitBreaker{},
+	}
+}
+
+func (cbs *CircuitBreakers) forURL(rawURL string) (*circuitBreaker, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse webhook url: %w", err)
+	}
+
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+
+	cb, found := cbs.byHost[u.Host]
+	if !found {
+		cb = &circuitBreaker{
+			host:             u.Host,
+			failureThreshold: cbs.failureThreshold,
+			coolDown:         cbs.coolDown,
+			mu:               &sync.Mutex{},
+		}
+		cbs.byHost[u.Host] = cb
+		circuitBreakerState.WithLabelValues(u.Host).Set(float64(circuitClosed))
+	}
+
+	return cb, nil
+}
+
+type circuitBreaker struct {
+	host             string
+	failureThreshold int
+	coolDown         time.Duration
+
+	mu       *sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// allow reports whether a request to the host may be attempted. Once the
+// cool-down of an open breaker has passed, exactly one caller gets to probe.
+func (cb *circuitBreaker) allow(now time.Time) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitClosed:
+		return true
+	case circuitOpen:
+		if now.Before(cb.openedAt.Add(cb.coolDown)) {
+			circuitBreakerRejected.WithLabelValues(cb.host).Inc()
+			return false
+		}
+		cb.setState(circuitHalfOpen)
+		cb.probing = true
+		return true
+	default:
+		if cb.probing {
+			circuitBreakerRejected.WithLabelValues(cb.host).Inc()
+			return false
+		}
+		cb.probing = true
+		return true
+	}
+}
+
+func (cb *circuitBreaker) success() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures = 0
+	cb.probing = false
+	cb.setState(circuitClosed)
+}
+
+func (cb *circuitBreaker) failure(now time.Time) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures++
+	cb.probing = false
+
+	if cb.state == circuitHalfOpen || cb.failures >= cb.failureThreshold {
+		cb.openedAt = now
+		cb.setState(circuitOpen)
+	}
+}
+
+// release gives up a request that was allowed but never made, so a probe
+// that was not sent doesn't keep the breaker half-open forever.
+func (cb *circuitBreaker) release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.probing = false
+}
+
+func (cb *circuitBreaker) currentState() circuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+func (cb *circuitBreaker) setState(s circuitState) {
+	cb.state = s
+	circuitBreakerState.WithLabelValues(cb.host).Set(float64(s))
+}
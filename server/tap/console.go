@@ -0,0 +1,105 @@
+package tap
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/draganm/event-tap/data"
+	"github.com/go-logr/logr"
+)
+
+// logBufferSize is the number of console entries kept per tap.
+const logBufferSize = 1000
+
+// LogBuffer is a bounded ring of console output. Every entry gets a sequence
+// number so readers can follow the buffer without missing or repeating entries.
+// It outlives restarts of the tap, so the numbers keep increasing.
+type LogBuffer struct {
+	mu      *sync.Mutex
+	entries []data.LogEntry
+	nextSeq uint64
+}
+
+func NewLogBuffer() *LogBuffer {
+	return &LogBuffer{
+		mu:      &sync.Mutex{},
+		nextSeq: 1,
+	}
+}
+
+func (lb *LogBuffer) add(level, message string) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	lb.entries = append(lb.entries, data.LogEntry{
+		Seq:     lb.nextSeq,
+		Time:    time.Now(),
+		Level:   level,
+		Message: message,
+	})
+	lb.nextSeq++
+
+	if len(lb.entries) > logBufferSize {
+		lb.entries = lb.entries[len(lb.entries)-logBufferSize:]
+	}
+}
+
+// Since returns the entries with a sequence number greater than after.
+func (lb *LogBuffer) Since(after uint64) []data.LogEntry {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	res := []data.LogEntry{}
+	for _, e := range lb.entries {
+		if e.Seq > after {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// installConsole defines console.log/info/warn/error in the runtime, writing
+// to both the logger and the log buffer.
+func installConsole(rt *goja.Runtime, log logr.Logger, lb *LogBuffer) error {
+	console := rt.NewObject()
+
+	for _, level := range []string{"log", "info", "warn", "error"} {
+		level := level
+		err := console.Set(level, func(call goja.FunctionCall) goja.Value {
+			msg := formatConsoleArgs(call.Arguments)
+			lb.add(level, msg)
+			if level == "error" {
+				log.Error(nil, msg, "source", "console")
+			} else {
+				log.Info(msg, "source", "console", "level", level)
+			}
+			return goja.Undefined()
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return rt.Set("console", console)
+}
+
+func formatConsoleArgs(args []goja.Value) string {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		if _, isObject := a.(*goja.Object); !isObject {
+			parts[i] = a.String()
+			continue
+		}
+
+		d, err := json.Marshal(a.Export())
+		if err != nil {
+			parts[i] = a.String()
+			continue
+		}
+		parts[i] = string(d)
+	}
+	return strings.Join(parts, " ")
+}
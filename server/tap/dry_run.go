@@ -0,0 +1,111 @@
+package tap
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/draganm/bolted/embedded"
+	"github.com/draganm/event-tap/data"
+	"github.com/go-logr/logr"
+)
+
+// DryRun runs the transform of the options against the given `[id, event]`
+// pairs without delivering anything. The transform runs in a sandbox with
+// its own empty state, so running it has no effect on installed taps.
+// Errors of the tap code are reported in the result, the returned error is
+// only set when the sandbox could not be created. Only the libraries and the
+// lookup hosts of the services are used.
+func DryRun(ctx context.Context, log logr.Logger, to data.TapOptions, events [][]any, services Services) (*data.TapTestResult, error) {
+	opts := options(to)
+
+	td, err := os.MkdirTemp("", "event-tap-dry-run")
+	if err != nil {
+		return nil, fmt.Errorf("could not create temp dir: %w", err)
+	}
+
+	defer os.RemoveAll(td)
+
+	db, err := embedded.Open(filepath.Join(td, "state"), 0700, embedded.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("could not open sandbox state: %w", err)
+	}
+
+	defer db.Close()
+
+	path := dbpath.ToPath("tap")
+	err = bolted.SugaredWrite(db, func(tx bolted.SugaredWriteTx) error {
+		tx.CreateMap(path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize sandbox state: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	logs := NewLogBuffer()
+	res := &data.TapTestResult{
+		Output: []any{},
+	}
+
+	defer func() {
+		res.Logs = logs.Since(0)
+	}()
+
+	started := time.Now()
+
+	var transpiled *Transpiled
+	if opts.Transform == "" || opts.Transform == TransformJavaScript {
+		transpiled, err = Transpile(opts.Language, opts.Code)
+		if err != nil {
+			res.Error = fmt.Errorf("could not transpile code: %w", err).Error()
+			return res, nil
+		}
+	}
+
+	tr, err := newTransform(ctx, log.WithValues("tap", opts.Name, "dryRun", true), db, path, opts, transpiled, Services{Libraries: services.Libraries, LookupHosts: services.LookupHosts}, logs)
+	res.InitDuration = time.Since(started).String()
+	if err != nil {
+		res.Error = err.Error()
+		return res, nil
+	}
+
+	started = time.Now()
+	output, err := tr.mapBatch(ctx, events)
+	if err == nil {
+		var dueOutput []any
+		dueOutput, err = tr.due(time.Now())
+		output = append(output, dueOutput...)
+	}
+	res.MapDuration = time.Since(started).String()
+
+	if err != nil {
+		res.Error = err.Error()
+		return res, nil
+	}
+
+	res.Output = append(res.Output, output...)
+
+	return res, nil
+}
+
+// SampleEventsWithIDs pairs sample events with their IDs the way the tap code
+// receives them. Events without an ID are numbered starting from 1.
+func SampleEventsWithIDs(events []data.SampleEvent) [][]any {
+	withIDs := make([][]any, len(events))
+	for i, e := range events {
+		id := e.ID
+		if id == "" {
+			id = strconv.Itoa(i + 1)
+		}
+		withIDs[i] = []any{id, e.Event}
+	}
+	return withIDs
+}
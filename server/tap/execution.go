@@ -0,0 +1,92 @@
+package tap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// executor runs tap code with an optional time limit. Go functions called
+// from the code (like http lookups) take their deadline from the context of
+// the current execution, so they count towards the same limit.
+type executor struct {
+	rt      *goja.Runtime
+	timeout time.Duration
+	ctx     context.Context
+}
+
+func newExecutor(rt *goja.Runtime, timeout string) (*executor, error) {
+	d, err := parseExecutionTimeout(timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &executor{rt: rt, ctx: context.Background(), timeout: d}, nil
+}
+
+func parseExecutionTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse execution_timeout: %w", err)
+	}
+
+	if d < 0 {
+		return 0, fmt.Errorf("execution_timeout must not be negative")
+	}
+
+	return d, nil
+}
+
+// run interrupts the code once the execution timeout is exceeded or the
+// context is done, so stopping a tap never waits for runaway code.
+func (e *executor) run(ctx context.Context, f func() (goja.Value, error)) (goja.Value, error) {
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+
+	if ctx.Done() != nil {
+		// the interrupt is only cleared once the goroutine is gone, so it
+		// can't hit the next execution
+		stop := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			select {
+			case <-ctx.Done():
+				if e.timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					e.rt.Interrupt(fmt.Sprintf("execution timeout of %s exceeded", e.timeout))
+					return
+				}
+				e.rt.Interrupt(fmt.Sprintf("execution stopped: %s", ctx.Err()))
+			case <-stop:
+			}
+		}()
+		defer func() {
+			close(stop)
+			<-stopped
+			e.rt.ClearInterrupt()
+		}()
+	}
+
+	e.ctx = ctx
+	defer func() {
+		e.ctx = context.Background()
+	}()
+
+	return f()
+}
+
+func (e *executor) call(ctx context.Context, fn goja.Callable, args ...goja.Value) (goja.Value, error) {
+	return e.run(ctx, func() (goja.Value, error) {
+		return fn(goja.Undefined(), args...)
+	})
+}
@@ -0,0 +1,322 @@
+package tap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/draganm/event-tap/data"
+)
+
+// maxLookupResponseSize limits how much of a lookup response is read.
+const maxLookupResponseSize = 10 * 1024 * 1024
+
+// maxCachedLookups limits the number of responses cached by a tap.
+const maxCachedLookups = 1000
+
+type lookupResponse struct {
+	status  int
+	headers map[string]string
+	body    string
+}
+
+type cachedResponse struct {
+	res     *lookupResponse
+	expires time.Time
+}
+
+// httpLookups implements the `http` object of the tap code, used to enrich
+// events with reference data. Only hosts on both the allow list of the
+// server and the one of the tap can be reached.
+type httpLookups struct {
+	tapName      string
+	serverHosts  []string
+	allowedHosts []string
+	timeout      time.Duration
+	cacheTTL     time.Duration
+	exec         *executor
+	client       *http.Client
+
+	mu    *sync.Mutex
+	cache map[string]cachedResponse
+}
+
+func newHTTPLookups(tapName string, serverHosts []string, opts *data.HTTPOptions, exec *executor) (*httpLookups, error) {
+	hl := &httpLookups{
+		tapName:     tapName,
+		serverHosts: serverHosts,
+		timeout:     10 * time.Second,
+		exec:        exec,
+		mu:          &sync.Mutex{},
+		cache:       map[string]cachedResponse{},
+	}
+
+	hl.client = &http.Client{CheckRedirect: hl.checkRedirect}
+
+	if opts == nil {
+		return hl, nil
+	}
+
+	hl.allowedHosts = opts.AllowedHosts
+
+	if opts.Timeout != "" {
+		d, err := time.ParseDuration(opts.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse http timeout: %w", err)
+		}
+		hl.timeout = d
+	}
+
+	if opts.CacheTTL != "" {
+		d, err := time.ParseDuration(opts.CacheTTL)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse http cache_ttl: %w", err)
+		}
+		hl.cacheTTL = d
+	}
+
+	return hl, nil
+}
+
+// allowed matches the host against the allow lists of the server and the
+// tap, the tap can only narrow down the hosts the server allows.
+func (hl *httpLookups) allowed(host string) bool {
+	return matchesHost(hl.serverHosts, host) && matchesHost(hl.allowedHosts, host)
+}
+
+// matchesHost matches the host against an allow list. Entries starting with
+// `*.` match any subdomain.
+func matchesHost(hosts []string, host string) bool {
+	for _, h := range hosts {
+		if h == host {
+			return true
+		}
+		if strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheKey identifies a GET request by its URL and headers, since headers
+// such as authorization can change the response.
+func cacheKey(rawURL string, headers map[string]string) string {
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	sb := &strings.Builder{}
+	sb.WriteString(rawURL)
+	for _, k := range keys {
+		fmt.Fprintf(sb, "\n%s: %s", strings.ToLower(k), headers[k])
+	}
+	return sb.String()
+}
+
+// cached returns the response cached under the key, removing it when it expired.
+func (hl *httpLookups) cached(key string, now time.Time) (*lookupResponse, bool) {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+
+	c, found := hl.cache[key]
+	if !found {
+		return nil, false
+	}
+
+	if !now.Before(c.expires) {
+		delete(hl.cache, key)
+		return nil, false
+	}
+
+	return c.res, true
+}
+
+// store caches the response. Expired responses are evicted first, when the
+// cache is still full the response expiring soonest makes room.
+func (hl *httpLookups) store(key string, res *lookupResponse, now time.Time) {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+
+	if _, found := hl.cache[key]; !found && len(hl.cache) >= maxCachedLookups {
+		soonest := ""
+		for k, c := range hl.cache {
+			if !now.Before(c.expires) {
+				delete(hl.cache, k)
+				continue
+			}
+			if soonest == "" || c.expires.Before(hl.cache[soonest].expires) {
+				soonest = k
+			}
+		}
+		if len(hl.cache) >= maxCachedLookups {
+			delete(hl.cache, soonest)
+		}
+	}
+
+	hl.cache[key] = cachedResponse{res: res, expires: now.Add(hl.cacheTTL)}
+}
+
+// checkRedirect only follows redirects to hosts on the allow list.
+func (hl *httpLookups) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if !hl.allowed(req.URL.Hostname()) {
+		return fmt.Errorf("redirect to host %s is not allowed", req.URL.Hostname())
+	}
+	return nil
+}
+
+func (hl *httpLookups) do(method, rawURL string, body []byte, headers map[string]string) (*lookupResponse, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse url: %w", err)
+	}
+
+	if !hl.allowed(u.Hostname()) {
+		return nil, fmt.Errorf("host %s is not allowed", u.Hostname())
+	}
+
+	cacheable := method == "GET" && hl.cacheTTL > 0
+	key := cacheKey(rawURL, headers)
+
+	if cacheable {
+		res, found := hl.cached(key, time.Now())
+		if found {
+			httpLookupsTotal.WithLabelValues(hl.tapName, u.Hostname(), method, "cached").Inc()
+			return res, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(hl.exec.ctx, hl.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	started := time.Now()
+	res, err := hl.client.Do(req)
+	httpLookupDuration.WithLabelValues(hl.tapName, u.Hostname()).Observe(time.Since(started).Seconds())
+	if err != nil {
+		httpLookupsTotal.WithLabelValues(hl.tapName, u.Hostname(), method, "error").Inc()
+		return nil, fmt.Errorf("could not perform request: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	rd, err := io.ReadAll(io.LimitReader(res.Body, maxLookupResponseSize))
+	if err != nil {
+		httpLookupsTotal.WithLabelValues(hl.tapName, u.Hostname(), method, "error").Inc()
+		return nil, fmt.Errorf("could not read response: %w", err)
+	}
+
+	httpLookupsTotal.WithLabelValues(hl.tapName, u.Hostname(), method, strconv.Itoa(res.StatusCode)).Inc()
+
+	lr := &lookupResponse{
+		status:  res.StatusCode,
+		headers: map[string]string{},
+		body:    string(rd),
+	}
+
+	for k := range res.Header {
+		lr.headers[strings.ToLower(k)] = res.Header.Get(k)
+	}
+
+	if cacheable && res.StatusCode >= 200 && res.StatusCode < 300 {
+		hl.store(key, lr, time.Now())
+	}
+
+	return lr, nil
+}
+
+// install defines http.get(url, headers) and http.post(url, body, headers).
+// Non-string bodies are sent as JSON.
+func (hl *httpLookups) install(rt *goja.Runtime) error {
+	jsonObject := rt.Get("JSON").ToObject(rt)
+	parse, _ := goja.AssertFunction(jsonObject.Get("parse"))
+	stringify, _ := goja.AssertFunction(jsonObject.Get("stringify"))
+
+	toJS := func(lr *lookupResponse) goja.Value {
+		res := rt.NewObject()
+		res.Set("status", lr.status)
+		res.Set("headers", lr.headers)
+		res.Set("body", lr.body)
+		res.Set("json", func() goja.Value {
+			v, err := parse(goja.Undefined(), rt.ToValue(lr.body))
+			if err != nil {
+				panic(err)
+			}
+			return v
+		})
+		return res
+	}
+
+	h := rt.NewObject()
+
+	err := h.Set("get", func(rawURL string, headers map[string]string) goja.Value {
+		lr, err := hl.do("GET", rawURL, nil, headers)
+		if err != nil {
+			panic(rt.NewGoError(err))
+		}
+		return toJS(lr)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = h.Set("post", func(rawURL string, body goja.Value, headers map[string]string) goja.Value {
+		if headers == nil {
+			headers = map[string]string{}
+		}
+
+		var d []byte
+		switch {
+		case body == nil || goja.IsUndefined(body) || goja.IsNull(body):
+		case isString(body):
+			d = []byte(body.String())
+		default:
+			v, err := stringify(goja.Undefined(), body)
+			if err != nil {
+				panic(err)
+			}
+			d = []byte(v.String())
+			if _, found := headers["content-type"]; !found {
+				headers["content-type"] = "application/json"
+			}
+		}
+
+		lr, err := hl.do("POST", rawURL, d, headers)
+		if err != nil {
+			panic(rt.NewGoError(err))
+		}
+		return toJS(lr)
+	})
+	if err != nil {
+		return err
+	}
+
+	return rt.Set("http", h)
+}
+
+func isString(v goja.Value) bool {
+	_, ok := v.Export().(string)
+	return ok
+}
@@ -0,0 +1,219 @@
+package tap
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/go-logr/logr"
+)
+
+// jsTransform runs tap code written in JavaScript, or transpiled to it,
+// calling the optional filterEvent and the mapEvents hooks.
+type jsTransform struct {
+	tapName     string
+	rt          *goja.Runtime
+	exec        *executor
+	state       *stateStore
+	windowStore *stateStore
+	windows     *windowAggregator
+	modules     *moduleLoader
+	mapEvents   goja.Callable
+	filterEvent goja.Callable
+}
+
+func newJSTransform(ctx context.Context, log logr.Logger, db bolted.Database, path dbpath.Path, opts options, transpiled *Transpiled, services Services, logs *LogBuffer) (*jsTransform, error) {
+	code := opts.Code
+	if transpiled != nil {
+		code = transpiled.runnable()
+	}
+
+	prg, err := goja.Compile("webhook.js", code, true)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse webhook code: %w", err)
+	}
+
+	rt := goja.New()
+	err = installConsole(rt, log, logs)
+	if err != nil {
+		return nil, fmt.Errorf("could not install console: %w", err)
+	}
+
+	state, err := newStateStore(db, path.Append("state"))
+	if err != nil {
+		return nil, err
+	}
+
+	err = installState(rt, state)
+	if err != nil {
+		return nil, fmt.Errorf("could not install state: %w", err)
+	}
+
+	exec, err := newExecutor(rt, opts.ExecutionTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	lookups, err := newHTTPLookups(opts.Name, services.LookupHosts, opts.HTTP, exec)
+	if err != nil {
+		return nil, err
+	}
+
+	err = lookups.install(rt)
+	if err != nil {
+		return nil, fmt.Errorf("could not install http: %w", err)
+	}
+
+	windowStore, err := newStateStore(db, path.Append("windows"))
+	if err != nil {
+		return nil, err
+	}
+
+	windows, err := installWindows(rt, windowStore, opts.Name)
+	if err != nil {
+		return nil, fmt.Errorf("could not install windows: %w", err)
+	}
+
+	modules, err := installRequire(rt, services.Libraries)
+	if err != nil {
+		return nil, fmt.Errorf("could not install require: %w", err)
+	}
+
+	// transpiled code is CommonJS, its hooks can be exported instead of global
+	var module *goja.Object
+	if transpiled != nil {
+		module = rt.NewObject()
+		exports := rt.NewObject()
+		module.Set("exports", exports)
+		rt.Set("module", module)
+		rt.Set("exports", exports)
+	}
+
+	hook := func(name string) goja.Value {
+		v := rt.Get(name)
+		if (v == nil || goja.IsUndefined(v)) && module != nil {
+			v = module.Get("exports").ToObject(rt).Get(name)
+		}
+		return v
+	}
+
+	_, err = exec.run(ctx, func() (goja.Value, error) {
+		return rt.RunProgram(prg)
+	})
+	if err != nil {
+		log.Error(err, "running code failed")
+		return nil, fmt.Errorf("could not run code: %w", err)
+	}
+
+	mapEvents, ok := goja.AssertFunction(hook("mapEvents"))
+	if !ok {
+		return nil, fmt.Errorf("could not find mapEvents function")
+	}
+
+	// filterEvent is optional, events it rejects are never passed to mapEvents
+	var filterEvent goja.Callable
+	if fv := hook("filterEvent"); fv != nil && !goja.IsUndefined(fv) {
+		filterEvent, ok = goja.AssertFunction(fv)
+		if !ok {
+			return nil, fmt.Errorf("filterEvent is not a function")
+		}
+	}
+
+	return &jsTransform{
+		tapName:     opts.Name,
+		rt:          rt,
+		exec:        exec,
+		state:       state,
+		windowStore: windowStore,
+		windows:     windows,
+		modules:     modules,
+		mapEvents:   mapEvents,
+		filterEvent: filterEvent,
+	}, nil
+}
+
+func (t *jsTransform) filter(ctx context.Context, events [][]any) ([][]any, error) {
+	if t.filterEvent == nil {
+		return events, nil
+	}
+
+	accepted := [][]any{}
+	for _, ev := range events {
+		keep, err := t.exec.call(ctx, t.filterEvent, t.rt.ToValue(ev[0]), t.rt.ToValue(ev[1]))
+		if err != nil {
+			return nil, fmt.Errorf("filterEvent failed: %w", err)
+		}
+		if keep.ToBoolean() {
+			accepted = append(accepted, ev)
+		}
+	}
+
+	eventsFiltered.WithLabelValues(t.tapName).Add(float64(len(events) - len(accepted)))
+
+	return accepted, nil
+}
+
+func (t *jsTransform) mapBatch(ctx context.Context, events [][]any) ([]any, error) {
+	events, err := t.filter(ctx, events)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	jsResult, err := t.exec.call(ctx, t.mapEvents, t.rt.ToValue(events))
+	if err != nil {
+		return nil, fmt.Errorf("mapEvents failed: %w", err)
+	}
+
+	var result []any
+	err = t.rt.ExportTo(jsResult, &result)
+	if err != nil {
+		return nil, fmt.Errorf("exportValues failed: %w", err)
+	}
+
+	return result, nil
+}
+
+func (t *jsTransform) due(now time.Time) ([]any, error) {
+	closed, err := t.windows.closeDue(now)
+	if err != nil {
+		return nil, fmt.Errorf("closing windows failed: %w", err)
+	}
+
+	err = t.windows.flush()
+	if err != nil {
+		return nil, fmt.Errorf("closing windows failed: %w", err)
+	}
+
+	return closed, nil
+}
+
+func (t *jsTransform) nextDue() (time.Time, bool) {
+	return t.windows.nextClose()
+}
+
+func (t *jsTransform) dirty() bool {
+	return t.state.dirty() || t.windowStore.dirty()
+}
+
+func (t *jsTransform) writeState(tx bolted.SugaredWriteTx) {
+	t.state.apply(tx)
+	t.windowStore.apply(tx)
+}
+
+func (t *jsTransform) stateWritten() {
+	t.state.discard()
+	t.windowStore.discard()
+}
+
+func (t *jsTransform) discardState() {
+	t.state.discard()
+	t.windowStore.discard()
+	t.windows.discard()
+}
@@ -0,0 +1,72 @@
+package tap
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/itchyny/gojq"
+)
+
+// jqTransform maps a batch with a jq program. The program gets the array of
+// `[id, event]` pairs as its input and has to produce a single array,
+// e.g. `map(.[1])`.
+type jqTransform struct {
+	stateless
+	code    *gojq.Code
+	timeout time.Duration
+}
+
+func newJQTransform(opts options) (*jqTransform, error) {
+	q, err := gojq.Parse(opts.Code)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse jq program: %w", err)
+	}
+
+	code, err := gojq.Compile(q)
+	if err != nil {
+		return nil, fmt.Errorf("could not compile jq program: %w", err)
+	}
+
+	timeout, err := parseExecutionTimeout(opts.ExecutionTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jqTransform{code: code, timeout: timeout}, nil
+}
+
+func (t *jqTransform) mapBatch(ctx context.Context, events [][]any) ([]any, error) {
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	input := make([]any, len(events))
+	for i, ev := range events {
+		input[i] = ev
+	}
+
+	iter := t.code.RunWithContext(ctx, input)
+
+	v, ok := iter.Next()
+	if !ok {
+		return nil, fmt.Errorf("jq program produced no output")
+	}
+
+	if err, isErr := v.(error); isErr {
+		return nil, fmt.Errorf("jq program failed: %w", err)
+	}
+
+	result, isArray := v.([]any)
+	if !isArray {
+		return nil, fmt.Errorf("jq program has to produce an array, got %T", v)
+	}
+
+	if _, more := iter.Next(); more {
+		return nil, fmt.Errorf("jq program has to produce a single array")
+	}
+
+	return result, nil
+}
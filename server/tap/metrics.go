@@ -0,0 +1,82 @@
+package tap
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	rateLimitWait = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "tap_rate_limit_wait_seconds",
+			Help: "Time deliveries spent waiting for the tap's rate limiter.",
+		},
+		[]string{"tap", "limit"},
+	)
+
+	transformDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "tap_transform_duration_seconds",
+			Help: "Time the transform of the tap took to map a batch of events.",
+		},
+		[]string{"tap", "transform"},
+	)
+
+	eventsFiltered = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tap_events_filtered_total",
+			Help: "Number of events rejected by the filterEvent function of the tap.",
+		},
+		[]string{"tap"},
+	)
+
+	windowLateEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tap_window_late_events_total",
+			Help: "Number of events dropped because their event time window had already closed.",
+		},
+		[]string{"tap", "window"},
+	)
+
+	httpLookupsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tap_http_lookups_total",
+			Help: "Number of http lookups made by tap code, by response status, `error` or `cached`.",
+		},
+		[]string{"tap", "host", "method", "result"},
+	)
+
+	httpLookupDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "tap_http_lookup_duration_seconds",
+			Help: "Duration of http lookups made by tap code.",
+		},
+		[]string{"tap", "host"},
+	)
+
+	circuitBreakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "circuit_breaker_state",
+			Help: "State of the circuit breaker of a webhook host: 0 closed, 1 half-open, 2 open.",
+		},
+		[]string{"host"},
+	)
+
+	circuitBreakerRejected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "circuit_breaker_rejected_total",
+			Help: "Number of deliveries not attempted because the circuit breaker of the host was open.",
+		},
+		[]string{"host"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		rateLimitWait,
+		transformDuration,
+		eventsFiltered,
+		windowLateEvents,
+		httpLookupsTotal,
+		httpLookupDuration,
+		circuitBreakerState,
+		circuitBreakerRejected,
+	)
+}
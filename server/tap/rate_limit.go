@@ -0,0 +1,105 @@
+package tap
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/draganm/event-tap/data"
+	"golang.org/x/time/rate"
+)
+
+// rateLimiter is a pair of token buckets limiting webhook requests and the
+// events sent with them. A limit of zero means unlimited.
+type rateLimiter struct {
+	requests *rate.Limiter
+	events   *rate.Limiter
+}
+
+func newRateLimiter(rl *data.RateLimit) *rateLimiter {
+	r := &rateLimiter{
+		requests: rate.NewLimiter(rate.Inf, 0),
+		events:   rate.NewLimiter(rate.Inf, 0),
+	}
+	r.set(rl)
+	return r
+}
+
+func (r *rateLimiter) set(rl *data.RateLimit) {
+	if rl == nil {
+		rl = &data.RateLimit{}
+	}
+	setLimit(r.requests, rl.RequestsPerSecond)
+	setLimit(r.events, rl.EventsPerSecond)
+}
+
+func setLimit(l *rate.Limiter, perSecond float64) {
+	if perSecond <= 0 {
+		l.SetLimit(rate.Inf)
+		return
+	}
+	l.SetBurst(int(math.Max(1, math.Ceil(perSecond))))
+	l.SetLimit(rate.Limit(perSecond))
+}
+
+// wait blocks until a request carrying the given number of events may be sent.
+func (r *rateLimiter) wait(ctx context.Context, tapName string, events int) error {
+	err := observeWait(tapName, "requests", func() error {
+		return r.requests.Wait(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
+	return observeWait(tapName, "events", func() error {
+		// WaitN refuses to wait for more tokens than the burst, so large
+		// payloads are paid for in burst sized chunks.
+		for events > 0 {
+			n := events
+			if b := r.events.Burst(); r.events.Limit() != rate.Inf && n > b {
+				n = b
+			}
+			err := r.events.WaitN(ctx, n)
+			if err != nil {
+				return err
+			}
+			events -= n
+		}
+		return nil
+	})
+}
+
+func observeWait(tapName, limit string, wait func() error) error {
+	started := time.Now()
+	err := wait()
+	rateLimitWait.WithLabelValues(tapName, limit).Observe(time.Since(started).Seconds())
+	return err
+}
+
+// SharedLimiter limits the webhook requests of a group of taps, like all
+// taps of a namespace. A limit of zero means unlimited.
+type SharedLimiter struct {
+	requests *rate.Limiter
+}
+
+func NewSharedLimiter(requestsPerSecond float64) *SharedLimiter {
+	l := &SharedLimiter{
+		requests: rate.NewLimiter(rate.Inf, 0),
+	}
+	l.Set(requestsPerSecond)
+	return l
+}
+
+// Set changes the limit for all taps sharing the limiter.
+func (l *SharedLimiter) Set(requestsPerSecond float64) {
+	setLimit(l.requests, requestsPerSecond)
+}
+
+func (l *SharedLimiter) wait(ctx context.Context, tapName string) error {
+	if l == nil {
+		return nil
+	}
+	return observeWait(tapName, "shared_requests", func() error {
+		return l.requests.Wait(ctx)
+	})
+}
@@ -0,0 +1,126 @@
+package tap
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// LatestVersion is the version tap code requires to follow the most recently
+// published version of a library.
+const LatestVersion = "latest"
+
+// Libraries resolves the shared modules tap code can require.
+type Libraries interface {
+	// Load returns the resolved version and the code of a library version,
+	// resolving LatestVersion to the most recently published one.
+	Load(name, version string) (string, string, error)
+}
+
+// ParseModuleID splits `name@version` into its parts. A missing version means latest.
+func ParseModuleID(id string) (string, string) {
+	idx := strings.LastIndex(id, "@")
+	if idx <= 0 {
+		return id, LatestVersion
+	}
+	return id[:idx], id[idx+1:]
+}
+
+// moduleLoader implements CommonJS style `require` for library modules.
+// Modules are loaded once per runtime, taps tracking the latest version are
+// restarted to pick up a new one.
+type moduleLoader struct {
+	rt      *goja.Runtime
+	libs    Libraries
+	modules map[string]*goja.Object
+	// resolved maps the required `name@version` to the key of its module
+	resolved map[string]string
+
+	mu           *sync.Mutex
+	tracksLatest map[string]bool
+}
+
+func installRequire(rt *goja.Runtime, libs Libraries) (*moduleLoader, error) {
+	ml := &moduleLoader{
+		rt:           rt,
+		libs:         libs,
+		modules:      map[string]*goja.Object{},
+		resolved:     map[string]string{},
+		mu:           &sync.Mutex{},
+		tracksLatest: map[string]bool{},
+	}
+
+	err := rt.Set("require", ml.require)
+	if err != nil {
+		return nil, err
+	}
+
+	return ml, nil
+}
+
+func (ml *moduleLoader) require(id string) goja.Value {
+	if ml.libs == nil {
+		panic(ml.rt.NewGoError(fmt.Errorf("libraries are not available")))
+	}
+
+	name, version := ParseModuleID(id)
+
+	module, found := ml.modules[ml.resolved[name+"@"+version]]
+	if found {
+		return module.Get("exports")
+	}
+
+	resolved, code, err := ml.libs.Load(name, version)
+	if err != nil {
+		panic(ml.rt.NewGoError(fmt.Errorf("could not load %s: %w", id, err)))
+	}
+
+	if version == LatestVersion {
+		ml.mu.Lock()
+		ml.tracksLatest[name] = true
+		ml.mu.Unlock()
+	}
+
+	key := name + "@" + resolved
+	ml.resolved[name+"@"+version] = key
+
+	module, found = ml.modules[key]
+	if found {
+		return module.Get("exports")
+	}
+
+	prg, err := goja.Compile(key+".js", "(function(exports, require, module) {\n"+code+"\n})", true)
+	if err != nil {
+		panic(ml.rt.NewGoError(fmt.Errorf("could not compile %s: %w", key, err)))
+	}
+
+	fv, err := ml.rt.RunProgram(prg)
+	if err != nil {
+		panic(err)
+	}
+
+	fn, _ := goja.AssertFunction(fv)
+
+	module = ml.rt.NewObject()
+	exports := ml.rt.NewObject()
+	module.Set("exports", exports)
+
+	// cached before running, so that cyclic requires see the partial exports
+	ml.modules[key] = module
+
+	_, err = fn(goja.Undefined(), exports, ml.rt.Get("require"), module)
+	if err != nil {
+		delete(ml.modules, key)
+		panic(err)
+	}
+
+	return module.Get("exports")
+}
+
+func (ml *moduleLoader) tracksLatestOf(name string) bool {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	return ml.tracksLatest[name]
+}
@@ -0,0 +1,149 @@
+package tap
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dop251/goja"
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+)
+
+type stateChange struct {
+	value   []byte
+	deleted bool
+}
+
+// stateStore is the durable key-value state of a tap. Changes made by the
+// tap code are kept in memory until they are applied in the transaction
+// that moves the cursor, so state and cursor never disagree.
+type stateStore struct {
+	db      bolted.Database
+	path    dbpath.Path
+	pending map[string]stateChange
+}
+
+func newStateStore(db bolted.Database, path dbpath.Path) (*stateStore, error) {
+	err := bolted.SugaredWrite(db, func(tx bolted.SugaredWriteTx) error {
+		if !tx.Exists(path) {
+			tx.CreateMap(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create state map: %w", err)
+	}
+
+	return &stateStore{
+		db:      db,
+		path:    path,
+		pending: map[string]stateChange{},
+	}, nil
+}
+
+func (s *stateStore) get(key string) ([]byte, bool, error) {
+	if c, found := s.pending[key]; found {
+		return c.value, !c.deleted, nil
+	}
+
+	var value []byte
+	var found bool
+	err := bolted.SugaredRead(s.db, func(tx bolted.SugaredReadTx) error {
+		kp := s.path.Append(key)
+		if tx.Exists(kp) {
+			value = tx.Get(kp)
+			found = true
+		}
+		return nil
+	})
+
+	return value, found, err
+}
+
+func (s *stateStore) put(key string, value []byte) {
+	s.pending[key] = stateChange{value: value}
+}
+
+func (s *stateStore) delete(key string) {
+	s.pending[key] = stateChange{deleted: true}
+}
+
+func (s *stateStore) dirty() bool {
+	return len(s.pending) > 0
+}
+
+// apply writes the pending changes within the given transaction.
+func (s *stateStore) apply(tx bolted.SugaredWriteTx) {
+	keys := make([]string, 0, len(s.pending))
+	for k := range s.pending {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		c := s.pending[k]
+		kp := s.path.Append(k)
+		switch {
+		case !c.deleted:
+			tx.Put(kp, c.value)
+		case tx.Exists(kp):
+			tx.Delete(kp)
+		}
+	}
+}
+
+// discard drops all changes that were not applied yet.
+func (s *stateStore) discard() {
+	s.pending = map[string]stateChange{}
+}
+
+// installState defines state.get/put/delete in the runtime. Values are
+// stored as JSON.
+func installState(rt *goja.Runtime, s *stateStore) error {
+	jsonObject := rt.Get("JSON").ToObject(rt)
+	parse, _ := goja.AssertFunction(jsonObject.Get("parse"))
+	stringify, _ := goja.AssertFunction(jsonObject.Get("stringify"))
+
+	state := rt.NewObject()
+
+	err := state.Set("get", func(key string) goja.Value {
+		d, found, err := s.get(key)
+		if err != nil {
+			panic(rt.NewGoError(fmt.Errorf("could not read state %s: %w", key, err)))
+		}
+		if !found {
+			return goja.Undefined()
+		}
+		v, err := parse(goja.Undefined(), rt.ToValue(string(d)))
+		if err != nil {
+			panic(err)
+		}
+		return v
+	})
+	if err != nil {
+		return err
+	}
+
+	err = state.Set("put", func(key string, value goja.Value) {
+		d, err := stringify(goja.Undefined(), value)
+		if err != nil {
+			panic(err)
+		}
+		if goja.IsUndefined(d) {
+			panic(rt.NewTypeError("state value for %s can't be serialized", key))
+		}
+		s.put(key, []byte(d.String()))
+	})
+	if err != nil {
+		return err
+	}
+
+	err = state.Set("delete", func(key string) {
+		s.delete(key)
+	})
+	if err != nil {
+		return err
+	}
+
+	return rt.Set("state", state)
+}
@@ -9,7 +9,6 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/dop251/goja"
 	"github.com/draganm/bolted"
 	"github.com/draganm/bolted/dbpath"
 	"github.com/draganm/event-buffer/client"
@@ -19,21 +18,72 @@ import (
 
 type options data.TapOptions
 
-func Start(ctx context.Context, log logr.Logger, db bolted.Database, path dbpath.Path, bufferClient *client.Client) error {
+// StatusRunning is the status of a tap that is processing events without errors.
+const StatusRunning = "running"
+
+// Tap is a handle to a running tap.
+type Tap struct {
+	limiter *rateLimiter
+	modules *moduleLoader
+	done    chan struct{}
+}
+
+// Done is closed once the tap stopped after its context was cancelled.
+func (t *Tap) Done() <-chan struct{} {
+	return t.done
+}
+
+// TracksLatest reports whether the tap code required the latest version of the library.
+func (t *Tap) TracksLatest(library string) bool {
+	return t.modules != nil && t.modules.tracksLatestOf(library)
+}
+
+// SetRateLimit changes the rate limit of the running tap.
+func (t *Tap) SetRateLimit(rl *data.RateLimit) {
+	t.limiter.set(rl)
+}
+
+// Services are shared by all taps of a server.
+type Services struct {
+	BufferClient *client.Client
+	Breakers     *CircuitBreakers
+	Libraries    Libraries
+	// SharedLimiter limits the requests of the tap together with other taps, it is optional.
+	SharedLimiter *SharedLimiter
+	// LookupHosts are the hosts http lookups of the tap code may reach, the
+	// allow list of the tap can only narrow them down. No lookups when empty.
+	LookupHosts []string
+	// Logs receives the console output of the tap, a new buffer is used when nil.
+	Logs *LogBuffer
+}
+
+func Start(ctx context.Context, log logr.Logger, db bolted.Database, path dbpath.Path, services Services) (*Tap, error) {
 	opts := options{}
+	var transpiled *Transpiled
 	err := bolted.SugaredRead(db, func(tx bolted.SugaredReadTx) error {
+		if tx.Exists(path.Append("transpiled")) {
+			transpiled = &Transpiled{
+				Code:      string(tx.Get(path.Append("transpiled"))),
+				SourceMap: string(tx.Get(path.Append("source_map"))),
+			}
+		}
 		return json.Unmarshal(tx.Get(path.Append("options")), &opts)
 	})
 
 	if err != nil {
-		return fmt.Errorf("could not load tap options: %w", err)
+		return nil, fmt.Errorf("could not load tap options: %w", err)
 	}
 
 	log = log.WithValues("tap", opts.Name)
 
-	prg, err := goja.Compile("webhook.js", opts.Code, true)
+	webhook, err := parseWebhookURL(opts.WebhookURL)
 	if err != nil {
-		return fmt.Errorf("could not parse webhook code: %w", err)
+		return nil, err
+	}
+
+	window, err := newBatchWindow(opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid batching options: %w", err)
 	}
 
 	lastID := ""
@@ -49,38 +99,57 @@ func Start(ctx context.Context, log logr.Logger, db bolted.Database, path dbpath
 	})
 
 	if err != nil {
-		return fmt.Errorf("could not determine last ID: %w", err)
+		return nil, fmt.Errorf("could not determine last ID: %w", err)
+	}
+
+	logs := services.Logs
+	if logs == nil {
+		logs = NewLogBuffer()
 	}
 
-	rt := goja.New()
-	_, err = rt.RunProgram(prg)
+	tr, err := newTransform(ctx, log, db, path, opts, transpiled, services, logs)
 	if err != nil {
-		log.Error(err, "running code failed")
-		return fmt.Errorf("could not run code: %w", err)
+		return nil, err
+	}
+
+	var modules *moduleLoader
+	if jst, isJS := tr.(*jsTransform); isJS {
+		modules = jst.modules
 	}
 
-	mapEvents, ok := goja.AssertFunction(rt.Get("mapEvents"))
-	if !ok {
-		return fmt.Errorf("could not find mapEvents function")
+	t := &Tap{
+		limiter: newRateLimiter(opts.RateLimit),
+		modules: modules,
+		done:    make(chan struct{}),
 	}
 
+	currentStatus := ""
+
 	updateStatus := func(status string) {
+		// a stopped tap leaves the status to whoever stopped it
+		if status == currentStatus || ctx.Err() != nil {
+			return
+		}
 		err := bolted.SugaredWrite(db, func(tx bolted.SugaredWriteTx) error {
 			tx.Put(path.Append("status"), []byte(status))
 			return nil
 		})
 		if err != nil {
 			log.Error(err, "could not update status")
+			return
 		}
+		currentStatus = status
 	}
 
-	postWebhook := func(ctx context.Context, payload any) error {
+	updateStatus(StatusRunning)
+
+	postWebhook := func(ctx context.Context, url string, payload any) error {
 		d, err := json.Marshal(payload)
 		if err != nil {
 			return fmt.Errorf("could not marshal payload: %w", err)
 		}
 
-		req, err := http.NewRequest("POST", opts.WebhookURL, bytes.NewReader(d))
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(d))
 		if err != nil {
 			return fmt.Errorf("could not create request: %w", err)
 		}
@@ -103,18 +172,87 @@ func Start(ctx context.Context, log logr.Logger, db bolted.Database, path dbpath
 
 	}
 
-	updateLastID := func(lastID string) error {
+	// deliver posts every delivery to its URL. Deliveries that fail are retried
+	// on their own, without re-posting the ones that have already succeeded.
+	deliver := func(ctx context.Context, deliveries []*delivery) error {
+		pending := deliveries
+		for {
+			failed := []*delivery{}
+			for _, d := range pending {
+				cb, err := services.Breakers.forURL(d.url)
+				if err != nil {
+					log.Error(err, "postWebhook failed", "url", d.url)
+					updateStatus(fmt.Errorf("postWebhook to %s failed: %w", d.url, err).Error())
+					failed = append(failed, d)
+					continue
+				}
+
+				// an open breaker rejects the request before it uses up rate limit tokens
+				if !cb.allow(time.Now()) {
+					updateStatus(fmt.Sprintf("circuit breaker for %s is %s", cb.host, cb.currentState()))
+					failed = append(failed, d)
+					continue
+				}
+
+				err = t.limiter.wait(ctx, opts.Name, len(d.payload))
+				if err == nil {
+					err = services.SharedLimiter.wait(ctx, opts.Name)
+				}
+				if err != nil {
+					cb.release()
+					return err
+				}
+
+				err = postWebhook(ctx, d.url, d.payload)
+				if err != nil && ctx.Err() != nil {
+					// the tap was stopped, the host did not fail
+					cb.release()
+					return ctx.Err()
+				}
+				if err != nil {
+					cb.failure(time.Now())
+					log.Error(err, "postWebhook failed", "url", d.url, "circuitBreaker", cb.currentState().String())
+					updateStatus(fmt.Errorf("postWebhook to %s failed: %w", d.url, err).Error())
+					failed = append(failed, d)
+					continue
+				}
+
+				cb.success()
+			}
+
+			if len(failed) == 0 {
+				return nil
+			}
+
+			pending = failed
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+				// try again
+			}
+		}
+	}
+
+	// commit stores the cursor together with the state changes made while
+	// mapping the events up to it.
+	commit := func(lastID string) error {
 		err := bolted.SugaredWrite(db, func(tx bolted.SugaredWriteTx) error {
 			tx.Put(lastIDPath, []byte(lastID))
+			tr.writeState(tx)
 			return nil
 		})
 		if err != nil {
 			log.Error(err, "could not update last id")
+			return err
 		}
+		tr.stateWritten()
 		return nil
 	}
 
 	go func() (err error) {
+		defer close(t.done)
 		defer func() {
 			if err != nil {
 				log.Error(err, "tap failed")
@@ -123,21 +261,51 @@ func Start(ctx context.Context, log logr.Logger, db bolted.Database, path dbpath
 			}
 		}()
 
-	mainLoop:
+		// pollID is the ID of the last event that was mapped, lastID the one
+		// of the last event that was delivered.
+		pollID := lastID
+		collected := &batch{}
+
+		// retry drops the collected batch and polls again from the stored cursor.
+		retry := func() bool {
+			collected.reset()
+			tr.discardState()
+			pollID = lastID
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(time.Second):
+				return true
+			}
+		}
+
 		for ctx.Err() == nil {
 
 			events := []any{}
 
-			ids, err := bufferClient.PollForEvents(ctx, lastID, opts.BatchLimit, &events)
-			if err != nil {
+			pollCtx, cancelPoll := ctx, context.CancelFunc(func() {})
+			dl, hasDeadline := window.deadline(collected)
+			if wdl, ok := tr.nextDue(); ok && (!hasDeadline || wdl.Before(dl)) {
+				dl, hasDeadline = wdl, true
+			}
+			if hasDeadline {
+				pollCtx, cancelPoll = context.WithDeadline(ctx, dl)
+			}
+
+			ids, err := services.BufferClient.PollForEvents(pollCtx, pollID, opts.BatchLimit, &events)
+			deadlinePassed := err != nil && ctx.Err() == nil && pollCtx.Err() != nil
+			cancelPoll()
+
+			switch {
+			case deadlinePassed:
+				ids, events = nil, nil
+			case err != nil:
 				log.Error(err, "polling events failed")
 				updateStatus(fmt.Errorf("could not poll events: %w", err).Error())
-				select {
-				case <-ctx.Done():
+				if !retry() {
 					return nil
-				case <-time.After(time.Second):
-					// try again
 				}
+				continue
 			}
 
 			eventsWithIDs := make([][]any, len(events))
@@ -147,69 +315,97 @@ func Start(ctx context.Context, log logr.Logger, db bolted.Database, path dbpath
 				eventsWithIDs[i] = []any{id, ev}
 			}
 
-			jsResult, err := mapEvents(goja.Undefined(), rt.ToValue(eventsWithIDs))
+			var result []any
+
+			if len(eventsWithIDs) > 0 {
+				started := time.Now()
+				result, err = tr.mapBatch(ctx, eventsWithIDs)
+				transformDuration.WithLabelValues(opts.Name, transformName(opts)).Observe(time.Since(started).Seconds())
+				if err != nil {
+					log.Error(err, "transform failed")
+					updateStatus(err.Error())
+					if !retry() {
+						return nil
+					}
+					continue
+				}
+			}
+
+			dueOutput, err := tr.due(time.Now())
 			if err != nil {
-				log.Error(err, "mapEvents failed")
-				updateStatus(fmt.Errorf("mapEvents failed: %w", err).Error())
-				select {
-				case <-ctx.Done():
+				log.Error(err, "transform failed")
+				updateStatus(err.Error())
+				if !retry() {
 					return nil
-				case <-time.After(time.Second):
-					continue mainLoop
 				}
+				continue
 			}
 
-			var result []any
-			err = rt.ExportTo(jsResult, &result)
+			result = append(result, dueOutput...)
+
+			err = collected.add(ids, result, time.Now())
 			if err != nil {
-				log.Error(err, "exportValues failed")
-				updateStatus(fmt.Errorf("exportValues failed: %w", err).Error())
-				select {
-				case <-ctx.Done():
+				log.Error(err, "collecting output failed")
+				updateStatus(fmt.Errorf("collecting output failed: %w", err).Error())
+				if !retry() {
 					return nil
-				case <-time.After(time.Second):
-					continue mainLoop
 				}
+				continue
 			}
 
-			if len(result) > 0 {
+			if len(ids) > 0 {
+				pollID = ids[len(ids)-1]
+			}
+
+			if !window.ready(collected, time.Now()) {
+				continue
+			}
 
-				err = postWebhook(ctx, result)
+			if len(collected.output) > 0 {
+
+				deliveries, err := webhook.split(collected.output)
 				if err != nil {
-					log.Error(err, "postWebhook failed")
-					updateStatus(fmt.Errorf("postWebhook failed: %w", err).Error())
-					select {
-					case <-ctx.Done():
+					log.Error(err, "resolving webhook urls failed")
+					updateStatus(fmt.Errorf("resolving webhook urls failed: %w", err).Error())
+					if !retry() {
 						return nil
-					case <-time.After(time.Second):
-						continue mainLoop
 					}
+					continue
+				}
+
+				err = deliver(ctx, deliveries)
+				if err != nil {
+					return nil
 				}
 			}
 
-			if len(ids) > 0 {
-				newLastID := ids[len(ids)-1]
-				err = updateLastID(newLastID)
+			newLastID := lastID
+			if collected.lastID != "" {
+				newLastID = collected.lastID
+			}
+
+			if newLastID != lastID || tr.dirty() {
+				err = commit(newLastID)
 				if err != nil {
 					log.Error(err, "updating last id failed")
 					updateStatus(fmt.Errorf("updating last id failed: %w", err).Error())
-					select {
-					case <-ctx.Done():
+					if !retry() {
 						return nil
-					case <-time.After(time.Second):
-						continue mainLoop
 					}
-				} else {
-					lastID = newLastID
+					continue
 				}
+				lastID = newLastID
 			}
 
+			collected.reset()
+			updateStatus(StatusRunning)
+
 		}
 
 		return nil
 
 	}()
 
-	return nil
+	return t, nil
 
 }
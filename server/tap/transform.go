@@ -0,0 +1,101 @@
+package tap
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/draganm/bolted"
+	"github.com/draganm/bolted/dbpath"
+	"github.com/draganm/event-tap/data"
+	"github.com/go-logr/logr"
+)
+
+const (
+	TransformJavaScript = "javascript"
+	TransformJQ         = "jq"
+	TransformCEL        = "cel"
+	TransformWASM       = "wasm"
+)
+
+// transform maps batches of `[id, event]` pairs to the output delivered to
+// the webhook. Transforms with state keep their changes pending until the
+// tap commits them together with the cursor.
+type transform interface {
+	// mapBatch maps a batch of events to the output array.
+	mapBatch(ctx context.Context, events [][]any) ([]any, error)
+
+	// due returns output that is due at the given time without any new
+	// events, like aggregates of windows that have closed.
+	due(now time.Time) ([]any, error)
+
+	// nextDue returns the time due has to be called at the latest.
+	nextDue() (time.Time, bool)
+
+	// dirty reports whether there are state changes to commit.
+	dirty() bool
+
+	// writeState applies the pending state changes within the transaction
+	// storing the cursor, stateWritten is called once it has been committed.
+	writeState(tx bolted.SugaredWriteTx)
+	stateWritten()
+
+	// discardState drops the pending state changes.
+	discardState()
+}
+
+// stateless implements the state handling of transforms without state.
+type stateless struct{}
+
+func (stateless) due(now time.Time) ([]any, error)    { return nil, nil }
+func (stateless) nextDue() (time.Time, bool)          { return time.Time{}, false }
+func (stateless) dirty() bool                         { return false }
+func (stateless) writeState(tx bolted.SugaredWriteTx) {}
+func (stateless) stateWritten()                       {}
+func (stateless) discardState()                       {}
+
+// ValidateTransform checks that the options name a known transform and that
+// its code compiles, without running it.
+func ValidateTransform(to data.TapOptions) error {
+	opts := options(to)
+	switch opts.Transform {
+	case "", TransformJavaScript:
+		_, err := Transpile(opts.Language, opts.Code)
+		return err
+	case TransformJQ:
+		_, err := newJQTransform(opts)
+		return err
+	case TransformCEL:
+		_, err := newCELTransform(opts)
+		return err
+	case TransformWASM:
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		_, err := newWASMTransform(ctx, opts)
+		return err
+	default:
+		return fmt.Errorf("unsupported transform %q", opts.Transform)
+	}
+}
+
+func newTransform(ctx context.Context, log logr.Logger, db bolted.Database, path dbpath.Path, opts options, transpiled *Transpiled, services Services, logs *LogBuffer) (transform, error) {
+	switch opts.Transform {
+	case "", TransformJavaScript:
+		return newJSTransform(ctx, log, db, path, opts, transpiled, services, logs)
+	case TransformJQ:
+		return newJQTransform(opts)
+	case TransformCEL:
+		return newCELTransform(opts)
+	case TransformWASM:
+		return newWASMTransform(ctx, opts)
+	default:
+		return nil, fmt.Errorf("unsupported transform %q", opts.Transform)
+	}
+}
+
+func transformName(opts options) string {
+	if opts.Transform == "" {
+		return TransformJavaScript
+	}
+	return opts.Transform
+}
@@ -0,0 +1,72 @@
+package tap
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+const (
+	LanguageJavaScript = "javascript"
+	LanguageTypeScript = "typescript"
+	LanguageModule     = "module"
+)
+
+// Transpiled is tap code converted to a script goja can run directly, along
+// with the source map pointing back to the original code.
+type Transpiled struct {
+	Code      string
+	SourceMap string
+}
+
+// Transpile converts TypeScript and ES module code to a CommonJS script.
+// Plain JavaScript is returned as nil, it is run as it is.
+func Transpile(language, code string) (*Transpiled, error) {
+	loader := api.LoaderJS
+	switch language {
+	case "", LanguageJavaScript:
+		return nil, nil
+	case LanguageTypeScript:
+		loader = api.LoaderTS
+	case LanguageModule:
+	default:
+		return nil, fmt.Errorf("unsupported language %q", language)
+	}
+
+	res := api.Transform(code, api.TransformOptions{
+		Loader:     loader,
+		Format:     api.FormatCommonJS,
+		Target:     api.ES2017,
+		Sourcemap:  api.SourceMapExternal,
+		Sourcefile: "tap." + sourceExtension(language),
+	})
+
+	if len(res.Errors) > 0 {
+		msgs := api.FormatMessages(res.Errors, api.FormatMessagesOptions{Kind: api.ErrorMessage})
+		return nil, errors.New(strings.TrimSpace(strings.Join(msgs, "\n")))
+	}
+
+	return &Transpiled{
+		Code:      string(res.Code),
+		SourceMap: string(res.Map),
+	}, nil
+}
+
+func sourceExtension(language string) string {
+	if language == LanguageTypeScript {
+		return "ts"
+	}
+	return "mjs"
+}
+
+// runnable returns the transpiled code with its source map inlined, so that
+// goja reports errors with the positions in the original code.
+func (t *Transpiled) runnable() string {
+	if t.SourceMap == "" {
+		return t.Code
+	}
+	return t.Code + "\n//# sourceMappingURL=data:application/json;base64," + base64.StdEncoding.EncodeToString([]byte(t.SourceMap))
+}
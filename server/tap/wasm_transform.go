@@ -0,0 +1,200 @@
+package tap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// wasmTransform maps a batch with a WebAssembly module. Every batch runs in
+// a fresh instance of the module, which has to follow this ABI:
+//
+//   - export its linear memory as `memory`
+//   - export `alloc(size i32) i32`, returning a pointer to size free bytes
+//   - export `map_events(ptr i32, len i32) i64`, taking the JSON array of
+//     `[id, event]` pairs written to the allocated memory and returning the
+//     location of the JSON output array as `ptr << 32 | len`
+//
+// An exported `_initialize` function is called once the module is
+// instantiated. WASI preview 1 is available to the module.
+type wasmTransform struct {
+	stateless
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	fuel     uint64
+	timeout  time.Duration
+}
+
+func newWASMTransform(ctx context.Context, opts options) (*wasmTransform, error) {
+	if opts.WASM == nil || len(opts.WASM.Module) == 0 {
+		return nil, fmt.Errorf("wasm transform needs a module")
+	}
+
+	timeout, err := parseExecutionTimeout(opts.ExecutionTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := wazero.NewRuntimeConfig().WithCloseOnContextDone(true)
+	if opts.WASM.MemoryLimitPages > 0 {
+		cfg = cfg.WithMemoryLimitPages(opts.WASM.MemoryLimitPages)
+	}
+
+	r := wazero.NewRuntimeWithConfig(ctx, cfg)
+
+	_, err = wasi_snapshot_preview1.Instantiate(ctx, r)
+	if err != nil {
+		r.Close(context.Background())
+		return nil, fmt.Errorf("could not instantiate wasi: %w", err)
+	}
+
+	// the fuel listener has to be known when the module is compiled
+	compileCtx := context.WithValue(ctx, experimental.FunctionListenerFactoryKey{}, fuelListenerFactory{})
+
+	compiled, err := r.CompileModule(compileCtx, opts.WASM.Module)
+	if err != nil {
+		r.Close(context.Background())
+		return nil, fmt.Errorf("could not compile wasm module: %w", err)
+	}
+
+	for _, fn := range []string{"alloc", "map_events"} {
+		if _, found := compiled.ExportedFunctions()[fn]; !found {
+			r.Close(context.Background())
+			return nil, fmt.Errorf("wasm module does not export %s", fn)
+		}
+	}
+
+	if _, found := compiled.ExportedMemories()["memory"]; !found {
+		r.Close(context.Background())
+		return nil, fmt.Errorf("wasm module does not export memory")
+	}
+
+	go func() {
+		<-ctx.Done()
+		r.Close(context.Background())
+	}()
+
+	return &wasmTransform{
+		runtime:  r,
+		compiled: compiled,
+		fuel:     opts.WASM.Fuel,
+		timeout:  timeout,
+	}, nil
+}
+
+func (t *wasmTransform) mapBatch(ctx context.Context, events [][]any) ([]any, error) {
+	input, err := json.Marshal(events)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal events: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if t.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	var fm *fuelMeter
+	if t.fuel > 0 {
+		fm = &fuelMeter{remaining: t.fuel, cancel: cancel}
+		ctx = context.WithValue(ctx, fuelMeterKey{}, fm)
+	}
+
+	mod, err := t.runtime.InstantiateModule(ctx, t.compiled, wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize"))
+	err = fm.explain(err)
+	if err != nil {
+		return nil, fmt.Errorf("could not instantiate wasm module: %w", err)
+	}
+
+	defer mod.Close(context.Background())
+
+	res, err := mod.ExportedFunction("alloc").Call(ctx, uint64(len(input)))
+	err = fm.explain(err)
+	if err != nil {
+		return nil, fmt.Errorf("alloc failed: %w", err)
+	}
+
+	ptr := uint32(res[0])
+	if !mod.Memory().Write(ptr, input) {
+		return nil, fmt.Errorf("alloc returned memory out of range")
+	}
+
+	res, err = mod.ExportedFunction("map_events").Call(ctx, uint64(ptr), uint64(len(input)))
+	err = fm.explain(err)
+	if err != nil {
+		return nil, fmt.Errorf("map_events failed: %w", err)
+	}
+
+	output, ok := mod.Memory().Read(uint32(res[0]>>32), uint32(res[0]))
+	if !ok {
+		return nil, fmt.Errorf("map_events returned memory out of range")
+	}
+
+	var result []any
+	err = json.Unmarshal(output, &result)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse output of map_events: %w", err)
+	}
+
+	return result, nil
+}
+
+type fuelMeterKey struct{}
+
+// fuelMeter limits the number of function calls of a single batch. wazero
+// has no instruction metering, so fuel is spent on calls, loops are bounded
+// by the execution timeout.
+type fuelMeter struct {
+	remaining uint64
+	exhausted bool
+	cancel    context.CancelFunc
+}
+
+var errFuelExhausted = errors.New("fuel exhausted")
+
+// explain attributes errors to exhausted fuel. Exhausting the fuel cancels
+// the call, but a call can still complete before wazero notices.
+func (fm *fuelMeter) explain(err error) error {
+	if fm == nil || !fm.exhausted {
+		return err
+	}
+	if err == nil {
+		return errFuelExhausted
+	}
+	return fmt.Errorf("%w: %s", errFuelExhausted, err.Error())
+}
+
+type fuelListenerFactory struct{}
+
+func (fuelListenerFactory) NewListener(api.FunctionDefinition) experimental.FunctionListener {
+	return fuelListener{}
+}
+
+type fuelListener struct{}
+
+func (fuelListener) Before(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) context.Context {
+	fm, found := ctx.Value(fuelMeterKey{}).(*fuelMeter)
+	if !found {
+		return ctx
+	}
+
+	if fm.remaining == 0 {
+		fm.exhausted = true
+		fm.cancel()
+		return ctx
+	}
+
+	fm.remaining--
+	return ctx
+}
+
+func (fuelListener) After(context.Context, api.Module, api.FunctionDefinition, error, []uint64) {}
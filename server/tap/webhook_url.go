@@ -0,0 +1,133 @@
+package tap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// webhookURL resolves the URL a mapped event should be delivered to.
+// Plain URLs resolve to themselves, templated ones (e.g. `http://host/{{.tenant}}/events`)
+// are executed against every event returned by mapEvents. The output of every
+// action is percent-encoded, so a value stays within its path segment or
+// query parameter and can't change the host, path or query of the URL.
+type webhookURL struct {
+	raw  string
+	tmpl *template.Template
+}
+
+func parseWebhookURL(raw string) (*webhookURL, error) {
+	if !strings.Contains(raw, "{{") {
+		return &webhookURL{raw: raw}, nil
+	}
+
+	tmpl, err := template.New("webhook_url").
+		Option("missingkey=error").
+		Funcs(template.FuncMap{escapeFunc: escapeURLValue}).
+		Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse webhook url template: %w", err)
+	}
+
+	for _, t := range tmpl.Templates() {
+		escapeActions(t.Tree.Root)
+	}
+
+	return &webhookURL{raw: raw, tmpl: tmpl}, nil
+}
+
+func (w *webhookURL) resolve(event any) (string, error) {
+	if w.tmpl == nil {
+		return w.raw, nil
+	}
+
+	buf := &bytes.Buffer{}
+	err := w.tmpl.Execute(buf, event)
+	if err != nil {
+		return "", fmt.Errorf("could not resolve webhook url: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+const escapeFunc = "_escape_url_value"
+
+// escapeActions pipes the output of every action of the template through
+// escapeURLValue, the way html/template adds its escapers.
+func escapeActions(n parse.Node) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			escapeActions(c)
+		}
+	case *parse.ActionNode:
+		// actions assigning variables don't output anything
+		if len(n.Pipe.Decl) == 0 {
+			n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+				NodeType: parse.NodeCommand,
+				Args:     []parse.Node{parse.NewIdentifier(escapeFunc)},
+			})
+		}
+	case *parse.IfNode:
+		escapeActions(n.List)
+		escapeActions(n.ElseList)
+	case *parse.RangeNode:
+		escapeActions(n.List)
+		escapeActions(n.ElseList)
+	case *parse.WithNode:
+		escapeActions(n.List)
+		escapeActions(n.ElseList)
+	}
+}
+
+// escapeURLValue percent-encodes everything but the unreserved characters of
+// RFC 3986, which are safe in paths as well as in queries.
+func escapeURLValue(v any) string {
+	s := fmt.Sprint(v)
+	b := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+type delivery struct {
+	url     string
+	payload []any
+}
+
+// split groups the mapped events by their resolved URL, keeping the order
+// of the events within a group and the order in which URLs first appeared.
+func (w *webhookURL) split(events []any) ([]*delivery, error) {
+	deliveries := []*delivery{}
+	byURL := map[string]*delivery{}
+
+	for _, ev := range events {
+		u, err := w.resolve(ev)
+		if err != nil {
+			return nil, err
+		}
+
+		d, found := byURL[u]
+		if !found {
+			d = &delivery{url: u}
+			byURL[u] = d
+			deliveries = append(deliveries, d)
+		}
+
+		d.payload = append(d.payload, ev)
+	}
+
+	return deliveries, nil
+}
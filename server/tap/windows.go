@@ -0,0 +1,420 @@
+package tap
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+const (
+	windowTimeEvent = "event"
+	windowTimeWall  = "wall"
+)
+
+type windowDef struct {
+	name     string
+	size     time.Duration
+	slide    time.Duration
+	timeMode string
+	lateness time.Duration
+}
+
+type openWindow struct {
+	key   string
+	start int64
+	end   int64
+	value goja.Value
+}
+
+type windowState struct {
+	watermark int64
+	open      map[string]*openWindow
+}
+
+type storedWindow struct {
+	Key   string          `json:"key"`
+	Start int64           `json:"start"`
+	End   int64           `json:"end"`
+	Value json.RawMessage `json:"value"`
+}
+
+type storedWindowState struct {
+	Watermark int64          `json:"watermark"`
+	Open      []storedWindow `json:"open"`
+}
+
+// windowAggregator implements the tumbling and sliding windows available to
+// the tap code as `windows`. Open windows are persisted in their own state
+// store and emitted as output once they close.
+type windowAggregator struct {
+	rt        *goja.Runtime
+	store     *stateStore
+	tapName   string
+	parse     goja.Callable
+	stringify goja.Callable
+
+	defs    map[string]*windowDef
+	order   []string
+	loaded  map[string]*windowState
+	changed bool
+}
+
+func installWindows(rt *goja.Runtime, store *stateStore, tapName string) (*windowAggregator, error) {
+	jsonObject := rt.Get("JSON").ToObject(rt)
+	parse, _ := goja.AssertFunction(jsonObject.Get("parse"))
+	stringify, _ := goja.AssertFunction(jsonObject.Get("stringify"))
+
+	wa := &windowAggregator{
+		rt:        rt,
+		store:     store,
+		tapName:   tapName,
+		parse:     parse,
+		stringify: stringify,
+		defs:      map[string]*windowDef{},
+		loaded:    map[string]*windowState{},
+	}
+
+	windows := rt.NewObject()
+
+	err := windows.Set("tumbling", func(name string, opts map[string]any) goja.Value {
+		return wa.define(name, opts, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = windows.Set("sliding", func(name string, opts map[string]any) goja.Value {
+		return wa.define(name, opts, true)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = rt.Set("windows", windows)
+	if err != nil {
+		return nil, err
+	}
+
+	return wa, nil
+}
+
+func (wa *windowAggregator) define(name string, opts map[string]any, sliding bool) goja.Value {
+	def, err := parseWindowDef(name, opts, sliding)
+	if err != nil {
+		panic(wa.rt.NewGoError(err))
+	}
+
+	if _, found := wa.defs[name]; !found {
+		wa.order = append(wa.order, name)
+	}
+	wa.defs[name] = def
+
+	handle := wa.rt.NewObject()
+	err = handle.Set("add", func(key string, reducer goja.Callable, eventTime goja.Value) {
+		err := wa.add(def, key, reducer, eventTime)
+		if err != nil {
+			panic(wa.rt.NewGoError(err))
+		}
+	})
+	if err != nil {
+		panic(wa.rt.NewGoError(err))
+	}
+
+	return handle
+}
+
+func parseWindowDef(name string, opts map[string]any, sliding bool) (*windowDef, error) {
+	def := &windowDef{name: name, timeMode: windowTimeEvent}
+
+	var err error
+
+	def.size, err = windowDuration(opts["size"])
+	if err != nil || def.size <= 0 {
+		return nil, fmt.Errorf("window %s: invalid size", name)
+	}
+
+	def.slide = def.size
+	if sliding {
+		def.slide, err = windowDuration(opts["slide"])
+		if err != nil || def.slide <= 0 || def.slide > def.size {
+			return nil, fmt.Errorf("window %s: invalid slide", name)
+		}
+	}
+
+	if v, found := opts["allowedLateness"]; found {
+		def.lateness, err = windowDuration(v)
+		if err != nil || def.lateness < 0 {
+			return nil, fmt.Errorf("window %s: invalid allowedLateness", name)
+		}
+	}
+
+	if v, found := opts["time"]; found {
+		def.timeMode = fmt.Sprint(v)
+		if def.timeMode != windowTimeEvent && def.timeMode != windowTimeWall {
+			return nil, fmt.Errorf("window %s: time must be %q or %q", name, windowTimeEvent, windowTimeWall)
+		}
+	}
+
+	return def, nil
+}
+
+// windowDuration accepts either a Go duration string or a number of milliseconds.
+func windowDuration(v any) (time.Duration, error) {
+	switch d := v.(type) {
+	case string:
+		return time.ParseDuration(d)
+	case int64:
+		return time.Duration(d) * time.Millisecond, nil
+	case float64:
+		return time.Duration(d * float64(time.Millisecond)), nil
+	default:
+		return 0, fmt.Errorf("unsupported duration %v", v)
+	}
+}
+
+// eventMillis converts a JS Date, a RFC3339 string or milliseconds since epoch.
+func eventMillis(v goja.Value) (int64, error) {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return 0, fmt.Errorf("event time is required for event time windows")
+	}
+
+	switch t := v.Export().(type) {
+	case time.Time:
+		return t.UnixMilli(), nil
+	case string:
+		pt, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return 0, fmt.Errorf("could not parse event time: %w", err)
+		}
+		return pt.UnixMilli(), nil
+	case int64:
+		return t, nil
+	case float64:
+		return int64(t), nil
+	default:
+		return 0, fmt.Errorf("unsupported event time %v", v)
+	}
+}
+
+func (wa *windowAggregator) add(def *windowDef, key string, reducer goja.Callable, eventTime goja.Value) error {
+	ws, err := wa.load(def.name)
+	if err != nil {
+		return err
+	}
+
+	ts := time.Now().UnixMilli()
+	if def.timeMode == windowTimeEvent {
+		ts, err = eventMillis(eventTime)
+		if err != nil {
+			return err
+		}
+	}
+
+	size := def.size.Milliseconds()
+	slide := def.slide.Milliseconds()
+	lateness := def.lateness.Milliseconds()
+
+	for start := floorDiv(ts, slide) * slide; start > ts-size; start -= slide {
+		end := start + size
+
+		if def.timeMode == windowTimeEvent && end+lateness <= ws.watermark {
+			windowLateEvents.WithLabelValues(wa.tapName, def.name).Inc()
+			continue
+		}
+
+		id := fmt.Sprintf("%s/%d", key, start)
+		w, found := ws.open[id]
+		if !found {
+			w = &openWindow{key: key, start: start, end: end, value: goja.Undefined()}
+			ws.open[id] = w
+		}
+
+		w.value, err = reducer(goja.Undefined(), w.value)
+		if err != nil {
+			return err
+		}
+		wa.changed = true
+	}
+
+	if def.timeMode == windowTimeEvent && ts > ws.watermark {
+		ws.watermark = ts
+	}
+
+	return nil
+}
+
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+func (wa *windowAggregator) load(name string) (*windowState, error) {
+	if ws, found := wa.loaded[name]; found {
+		return ws, nil
+	}
+
+	ws := &windowState{open: map[string]*openWindow{}}
+
+	d, found, err := wa.store.get(name)
+	if err != nil {
+		return nil, fmt.Errorf("could not load window %s: %w", name, err)
+	}
+
+	if found {
+		sws := storedWindowState{}
+		err = json.Unmarshal(d, &sws)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse window %s: %w", name, err)
+		}
+
+		ws.watermark = sws.Watermark
+		for _, sw := range sws.Open {
+			v, err := wa.parse(goja.Undefined(), wa.rt.ToValue(string(sw.Value)))
+			if err != nil {
+				return nil, fmt.Errorf("could not parse value of window %s: %w", name, err)
+			}
+			ws.open[fmt.Sprintf("%s/%d", sw.Key, sw.Start)] = &openWindow{
+				key:   sw.Key,
+				start: sw.Start,
+				end:   sw.End,
+				value: v,
+			}
+		}
+	}
+
+	wa.loaded[name] = ws
+
+	return ws, nil
+}
+
+// closeDue removes the windows that have closed and returns their aggregates.
+func (wa *windowAggregator) closeDue(now time.Time) ([]any, error) {
+	emitted := []any{}
+
+	for _, name := range wa.order {
+		def := wa.defs[name]
+		ws, err := wa.load(name)
+		if err != nil {
+			return nil, err
+		}
+
+		closed := []*openWindow{}
+		for id, w := range ws.open {
+			isClosed := false
+			switch def.timeMode {
+			case windowTimeWall:
+				isClosed = w.end <= now.UnixMilli()
+			default:
+				isClosed = w.end+def.lateness.Milliseconds() <= ws.watermark
+			}
+
+			if isClosed {
+				closed = append(closed, w)
+				delete(ws.open, id)
+				wa.changed = true
+			}
+		}
+
+		sort.Slice(closed, func(i, j int) bool {
+			if closed[i].end != closed[j].end {
+				return closed[i].end < closed[j].end
+			}
+			return closed[i].key < closed[j].key
+		})
+
+		for _, w := range closed {
+			emitted = append(emitted, map[string]any{
+				"window": name,
+				"key":    w.key,
+				"start":  w.start,
+				"end":    w.end,
+				"value":  w.value.Export(),
+			})
+		}
+	}
+
+	return emitted, nil
+}
+
+// nextClose returns the earliest time a wall clock window closes.
+func (wa *windowAggregator) nextClose() (time.Time, bool) {
+	var next int64
+	found := false
+
+	for _, name := range wa.order {
+		if wa.defs[name].timeMode != windowTimeWall {
+			continue
+		}
+
+		ws, err := wa.load(name)
+		if err != nil {
+			// the error is reported by closeDue
+			continue
+		}
+
+		for _, w := range ws.open {
+			if !found || w.end < next {
+				next = w.end
+				found = true
+			}
+		}
+	}
+
+	return time.UnixMilli(next), found
+}
+
+// flush stores changed windows as pending changes of the state store.
+func (wa *windowAggregator) flush() error {
+	if !wa.changed {
+		return nil
+	}
+
+	for name, ws := range wa.loaded {
+		sws := storedWindowState{
+			Watermark: ws.watermark,
+			Open:      []storedWindow{},
+		}
+
+		for _, w := range ws.open {
+			v, err := wa.stringify(goja.Undefined(), w.value)
+			if err != nil {
+				return fmt.Errorf("could not serialize window %s: %w", name, err)
+			}
+			value := json.RawMessage("null")
+			if !goja.IsUndefined(v) {
+				value = json.RawMessage(v.String())
+			}
+			sws.Open = append(sws.Open, storedWindow{Key: w.key, Start: w.start, End: w.end, Value: value})
+		}
+
+		sort.Slice(sws.Open, func(i, j int) bool {
+			if sws.Open[i].Start != sws.Open[j].Start {
+				return sws.Open[i].Start < sws.Open[j].Start
+			}
+			return sws.Open[i].Key < sws.Open[j].Key
+		})
+
+		d, err := json.Marshal(sws)
+		if err != nil {
+			return fmt.Errorf("could not marshal window %s: %w", name, err)
+		}
+
+		wa.store.put(name, d)
+	}
+
+	wa.changed = false
+
+	return nil
+}
+
+// discard forgets the loaded windows so they are read again from the store.
+func (wa *windowAggregator) discard() {
+	wa.loaded = map[string]*windowState{}
+	wa.changed = false
+}
@@ -13,17 +13,36 @@ import (
 )
 
 func StartServer(ctx context.Context, log logr.Logger, buferBaseURL string) (string, error) {
+	return StartServerWithConfig(ctx, log, buferBaseURL, server.Config{})
+}
+
+// StartServerWithConfig starts a server on a new state file with the given settings.
+func StartServerWithConfig(ctx context.Context, log logr.Logger, buferBaseURL string, cfg server.Config) (string, error) {
 	td, err := os.MkdirTemp("", "")
 	if err != nil {
 		return "", fmt.Errorf("could not create temp dir: %w", err)
 	}
 
-	db, err := embedded.Open(filepath.Join(td, "db"), 0700, embedded.Options{})
+	go func() {
+		<-ctx.Done()
+		os.RemoveAll(td)
+	}()
+
+	return startServer(ctx, log, buferBaseURL, filepath.Join(td, "db"), cfg)
+}
+
+// StartServerOnState starts a server on an existing state file.
+func StartServerOnState(ctx context.Context, log logr.Logger, buferBaseURL, stateFile string) (string, error) {
+	return startServer(ctx, log, buferBaseURL, stateFile, server.Config{})
+}
+
+func startServer(ctx context.Context, log logr.Logger, buferBaseURL, stateFile string, cfg server.Config) (string, error) {
+	db, err := embedded.Open(stateFile, 0700, embedded.Options{})
 	if err != nil {
 		return "", fmt.Errorf("could not open db: %w", err)
 	}
 
-	server, err := server.New(log, db, buferBaseURL)
+	server, err := server.New(log, db, buferBaseURL, cfg)
 	if err != nil {
 		return "", fmt.Errorf("could not start tap server: %w", err)
 	}
@@ -34,7 +53,6 @@ func StartServer(ctx context.Context, log logr.Logger, buferBaseURL string) (str
 		<-ctx.Done()
 		hs.Close()
 		db.Close()
-		os.RemoveAll(td)
 	}()
 
 	return hs.URL, nil
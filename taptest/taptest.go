@@ -0,0 +1,168 @@
+// Package taptest runs tap code offline, without an event-tap server or an
+// event buffer. It uses the same transforms as running taps, so mapping code
+// can be unit tested against fixture events and golden files.
+package taptest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/draganm/event-tap/data"
+	"github.com/draganm/event-tap/server/tap"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+)
+
+// UpdateEnv is the environment variable that makes AssertGolden write the
+// golden files instead of comparing against them.
+const UpdateEnv = "TAPTEST_UPDATE"
+
+// Harness runs tap code against fixture events.
+type Harness struct {
+	Options data.TapOptions
+	// Libraries resolves the modules the tap code requires, requiring fails when nil.
+	Libraries tap.Libraries
+	// LookupHosts are the hosts http lookups can reach, the allow list of
+	// the options can only narrow them down. No lookups when empty.
+	LookupHosts []string
+	Log         logr.Logger
+}
+
+// Run maps the events in a sandbox with empty state. Errors of the tap code
+// are reported in the result, the returned error is only set when the
+// sandbox could not be created.
+func (h Harness) Run(ctx context.Context, events []data.SampleEvent) (*data.TapTestResult, error) {
+	log := h.Log
+	if log.GetSink() == nil {
+		log = logr.Discard()
+	}
+	return tap.DryRun(ctx, log, h.Options, tap.SampleEventsWithIDs(events), tap.Services{
+		Libraries:   h.Libraries,
+		LookupHosts: h.LookupHosts,
+	})
+}
+
+// Run maps the events with the given options, see Harness.Run.
+func Run(ctx context.Context, opts data.TapOptions, events []data.SampleEvent) (*data.TapTestResult, error) {
+	return Harness{Options: opts}.Run(ctx, events)
+}
+
+// ReadEvents reads fixture events with one JSON event per line. Empty lines are skipped.
+func ReadEvents(r io.Reader) ([]data.SampleEvent, error) {
+	events := []data.SampleEvent{}
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 16*1024*1024)
+	for s.Scan() {
+		line := bytes.TrimSpace(s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var ev any
+		err := json.Unmarshal(line, &ev)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse event %d: %w", len(events)+1, err)
+		}
+		events = append(events, data.SampleEvent{Event: ev})
+	}
+
+	err := s.Err()
+	if err != nil {
+		return nil, fmt.Errorf("could not read events: %w", err)
+	}
+
+	return events, nil
+}
+
+// ReadEventsFile reads fixture events from a file, see ReadEvents.
+func ReadEventsFile(fileName string) ([]data.SampleEvent, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not open events file: %w", err)
+	}
+
+	defer f.Close()
+
+	return ReadEvents(f)
+}
+
+// WriteGolden stores the output as an indented JSON array.
+func WriteGolden(fileName string, output []any) error {
+	d, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal output: %w", err)
+	}
+
+	err = os.WriteFile(fileName, append(d, '\n'), 0644)
+	if err != nil {
+		return fmt.Errorf("could not write golden file: %w", err)
+	}
+
+	return nil
+}
+
+// CompareGolden returns an error with the differences when the output does
+// not match the golden file. Formatting of the golden file does not matter.
+func CompareGolden(fileName string, output []any) error {
+	gd, err := os.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("could not read golden file: %w", err)
+	}
+
+	var expected any
+	err = json.Unmarshal(gd, &expected)
+	if err != nil {
+		return fmt.Errorf("could not parse golden file: %w", err)
+	}
+
+	// round trip the output so both sides have the types JSON decoding produces
+	od, err := json.Marshal(output)
+	if err != nil {
+		return fmt.Errorf("could not marshal output: %w", err)
+	}
+
+	var actual any
+	err = json.Unmarshal(od, &actual)
+	if err != nil {
+		return fmt.Errorf("could not parse output: %w", err)
+	}
+
+	diff := cmp.Diff(expected, actual)
+	if diff != "" {
+		return fmt.Errorf("output does not match %s (-golden +output):\n%s", fileName, diff)
+	}
+
+	return nil
+}
+
+// AssertGolden fails the test when the output does not match the golden
+// file. When UpdateEnv is set, the golden file is written instead.
+func AssertGolden(t testing.TB, fileName string, res *data.TapTestResult) {
+	t.Helper()
+
+	if res.Error != "" {
+		t.Fatalf("tap code failed: %s", res.Error)
+	}
+
+	if os.Getenv(UpdateEnv) != "" {
+		err := WriteGolden(fileName, res.Output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	err := CompareGolden(fileName, res.Output)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("%s, run with %s=1 to create it", err, UpdateEnv)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
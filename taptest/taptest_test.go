@@ -0,0 +1,33 @@
+package taptest_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/draganm/event-tap/data"
+	"github.com/draganm/event-tap/taptest"
+)
+
+func TestRunAgainstGoldenFile(t *testing.T) {
+	code, err := os.ReadFile("testdata/double.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := taptest.ReadEventsFile("testdata/events.ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := taptest.Run(context.Background(), data.TapOptions{Name: "double", Code: string(code)}, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	taptest.AssertGolden(t, "testdata/double.golden.json", res)
+
+	if len(res.Logs) != 1 || res.Logs[0].Message != "mapping 2" {
+		t.Fatalf("unexpected logs %v", res.Logs)
+	}
+}
@@ -0,0 +1,4 @@
+function mapEvents(evts) {
+    console.log("mapping", evts.length)
+    return evts.map(([id, evt]) => ({ id, v: evt.v * 2 }))
+}